@@ -0,0 +1,213 @@
+package files
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RenameMove est un renommage effectué
+type RenameMove struct {
+	Old     string    `json:"old"`
+	New     string    `json:"new"`
+	Size    int64     `json:"size,omitempty"`    // taille du fichier après renommage
+	ModTime time.Time `json:"mod_time,omitzero"` // date de modification après renommage
+}
+
+// RenameJournalEntry est un lot de renommages enregistré dans le journal
+type RenameJournalEntry struct {
+	ID     string       `json:"id"`
+	Date   time.Time    `json:"date"`
+	Moves  []RenameMove `json:"moves"` // dans l'ordre du lot
+	Undone bool         `json:"undone"`
+}
+
+// Nombre de lots conservés dans le journal
+const maxJournalEntries = 100
+
+var (
+	journalFile  string
+	journalMutex sync.Mutex
+)
+
+// init place le journal dans Documents/AltesseTools, comme les statistiques
+func init() {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		journalFile = "rename_journal.json" // fallback si pas de home
+		return
+	}
+
+	docs := filepath.Join(home, "Documents", "AltesseTools")
+	_ = os.MkdirAll(docs, 0755)
+	journalFile = filepath.Join(docs, "rename_journal.json")
+}
+
+func loadJournal() ([]RenameJournalEntry, error) {
+	data, err := os.ReadFile(journalFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []RenameJournalEntry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("journal de renommage inaccessible : %w", err)
+	}
+	var entries []RenameJournalEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("journal de renommage illisible : %w", err)
+	}
+	return entries, nil
+}
+
+func saveJournal(entries []RenameJournalEntry) error {
+	if len(entries) > maxJournalEntries {
+		entries = entries[len(entries)-maxJournalEntries:]
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Écriture dans un fichier temporaire puis remplacement : un arrêt en cours d'écriture
+	// laisse l'ancien journal intact
+	tmp := journalFile + ".tmp"
+	if err := writeSynced(tmp, data); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("échec écriture du journal de renommage : %w", err)
+	}
+	if err := os.Rename(tmp, journalFile); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("échec écriture du journal de renommage : %w", err)
+	}
+	return nil
+}
+
+// writeSynced écrit le fichier et force son écriture sur le disque.
+func writeSynced(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// recordRenameBatch ajoute un lot au journal et retourne son identifiant. La taille et la
+// date de chaque fichier sont relevées pour vérifier son identité à l'annulation.
+func recordRenameBatch(moves []RenameMove) (string, error) {
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+
+	for i := range moves {
+		if info, err := os.Stat(moves[i].New); err == nil {
+			moves[i].Size = info.Size()
+			moves[i].ModTime = info.ModTime()
+		}
+	}
+
+	entries, err := loadJournal()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	id := strconv.FormatInt(now.UnixNano(), 36)
+	entries = append(entries, RenameJournalEntry{ID: id, Date: now, Moves: moves})
+	return id, saveJournal(entries)
+}
+
+// RenameHistory retourne les lots du journal, du plus récent au plus ancien.
+func RenameHistory() ([]RenameJournalEntry, error) {
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+
+	entries, err := loadJournal()
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// UndoRenameBatch remet les fichiers du lot à leur ancien nom, en une seule opération
+// atomique. Rien n'est fait si un fichier a été déplacé depuis ou si son ancien nom est repris.
+func UndoRenameBatch(id string) error {
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+
+	entries, err := loadJournal()
+	if err != nil {
+		return err
+	}
+	index := -1
+	for i := range entries {
+		if entries[i].ID == id {
+			index = i
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("lot de renommage '%s' introuvable", id)
+	}
+	entry := &entries[index]
+	if entry.Undone {
+		return fmt.Errorf("le lot '%s' a déjà été annulé", id)
+	}
+
+	if problems := checkUndo(entry.Moves); len(problems) > 0 {
+		if len(problems) > 5 {
+			problems = append(problems[:5], fmt.Sprintf("… et %d autres", len(problems)-5))
+		}
+		return fmt.Errorf("annulation impossible :\n%s", strings.Join(problems, "\n"))
+	}
+
+	reverse := make([]RenameMove, len(entry.Moves))
+	for i, m := range entry.Moves {
+		reverse[len(reverse)-1-i] = RenameMove{Old: m.New, New: m.Old}
+	}
+	if err := applyMoves(reverse); err != nil {
+		return err
+	}
+	entry.Undone = true
+	return saveJournal(entries)
+}
+
+// checkUndo vérifie que chaque fichier est toujours à son nouveau chemin, avec la taille et la
+// date relevées au renommage, et que l'ancien est libre, ou occupé par un autre fichier du lot
+// qui sera lui aussi remis en place.
+func checkUndo(moves []RenameMove) []string {
+	var problems []string
+	keys := pathKeys{}
+	inBatch := map[string]bool{}
+	for _, m := range moves {
+		inBatch[keys.key(m.New)] = true
+	}
+	for _, m := range moves {
+		info, err := os.Stat(m.New)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s a été déplacé ou supprimé", m.New))
+			continue
+		}
+		// Les lots journalisés avant le relevé d'identité n'ont ni taille ni date
+		if !m.ModTime.IsZero() && (info.Size() != m.Size || !info.ModTime().Equal(m.ModTime)) {
+			problems = append(problems, fmt.Sprintf("%s a été remplacé ou modifié depuis le renommage", m.New))
+			continue
+		}
+		if _, err := os.Stat(m.Old); err == nil && !samePath(m.Old, m.New) && !inBatch[keys.key(m.Old)] {
+			problems = append(problems, fmt.Sprintf("%s existe de nouveau", m.Old))
+		}
+	}
+	return problems
+}
@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 )
 
-// OptionRename définit les paramètres du batch rename
+// OptionRename définit les paramètres du batch rename de l'écran historique. Ses modes
+// s'excluent (NewName ignore Replace, la numérotation ignore Suffix) : il est converti en
+// recette équivalente, préférer RenameRecipe pour les nouveaux usages.
 type OptionRename struct {
 	NewName     string
 	Prefix      string
@@ -18,56 +21,289 @@ type OptionRename struct {
 	Padding     int
 }
 
+// RenamePair est un renommage prévu
+type RenamePair struct {
+	Old       string   `json:"old"`
+	New       string   `json:"new"`
+	Conflicts []string `json:"conflicts,omitempty"` // raisons qui empêcheraient ce renommage
+}
+
+// RenamePreview est le résultat d'une simulation de renommage
+type RenamePreview struct {
+	Pairs     []RenamePair `json:"pairs"`
+	Conflicts int          `json:"conflicts"` // nombre de paires en conflit
+}
+
+// Caractères interdits dans un nom de fichier sous Windows
+const invalidNameChars = `<>:"/\|?*`
+
+// Noms réservés par Windows, quelle que soit l'extension
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
 // RenameBatch renomme un tableau de fichiers/dossiers selon les options
 func RenameBatch(paths []string, opts OptionRename) error {
-	for i, oldPath := range paths {
-		dir := filepath.Dir(oldPath)
-		base := filepath.Base(oldPath)
-		ext := filepath.Ext(base)
-		name := strings.TrimSuffix(base, ext)
-
-		// Renommer complètement si NewName est défini
-		if opts.NewName != "" {
-			// ajouter une numérotation automatique
-			if len(paths) > 1 {
-				padding := opts.Padding
-				if padding == 0 {
-					padding = 3 // padding par défaut
+	return RenameWithRecipe(paths, opts.recipe(len(paths)))
+}
+
+// RenameWithRecipe renomme les fichiers en appliquant les règles de la recette.
+// Le lot est atomique : tout est vérifié avant de commencer, les permutations (a→b, b→a)
+// passent par des noms temporaires et, si un renommage échoue, les précédents sont annulés.
+// Les lots réussis sont enregistrés dans le journal pour pouvoir être annulés.
+func RenameWithRecipe(paths []string, recipe RenameRecipe) error {
+	preview, err := PreviewRecipe(paths, recipe)
+	if err != nil {
+		return err
+	}
+	if preview.Conflicts > 0 {
+		var lines []string
+		for _, p := range preview.Pairs {
+			if len(p.Conflicts) > 0 && len(lines) < 5 {
+				lines = append(lines, fmt.Sprintf("%s → %s : %s", p.Old, filepath.Base(p.New), strings.Join(p.Conflicts, ", ")))
+			}
+		}
+		if preview.Conflicts > len(lines) {
+			lines = append(lines, fmt.Sprintf("… et %d autres", preview.Conflicts-len(lines)))
+		}
+		return fmt.Errorf("renommage annulé, aucun fichier modifié :\n%s", strings.Join(lines, "\n"))
+	}
+
+	var moves []RenameMove
+	for _, p := range preview.Pairs {
+		if p.New != p.Old {
+			moves = append(moves, RenameMove{Old: p.Old, New: p.New})
+		}
+	}
+	if len(moves) == 0 {
+		return nil
+	}
+	if err := applyMoves(moves); err != nil {
+		return err
+	}
+	if _, err := recordRenameBatch(moves); err != nil {
+		return fmt.Errorf("renommage effectué mais journal non enregistré : %w", err)
+	}
+	return nil
+}
+
+// applyMoves exécute les renommages dans un ordre qui libère chaque cible avant de l'occuper.
+// Un cycle est rompu en déplaçant un fichier sous un nom temporaire. En cas d'échec, les
+// renommages faits sont défaits en ordre inverse.
+func applyMoves(moves []RenameMove) error {
+	type step struct{ from, to string }
+	var done []step
+	keys := pathKeys{}
+	freed := map[string]bool{} // emplacements libérés par le lot
+	rename := func(from, to string) error {
+		if err := os.Rename(from, to); err != nil {
+			return err
+		}
+		done = append(done, step{from, to})
+		freed[keys.key(from)] = true
+		delete(freed, keys.key(to))
+		return nil
+	}
+	rollback := func(cause error) error {
+		var failed []string
+		for i := len(done) - 1; i >= 0; i-- {
+			if err := os.Rename(done[i].to, done[i].from); err != nil {
+				failed = append(failed, fmt.Sprintf("%s → %s", done[i].to, done[i].from))
+			}
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("%w ; retour arrière incomplet, à rétablir à la main :\n%s", cause, strings.Join(failed, "\n"))
+		}
+		return fmt.Errorf("%w ; les renommages déjà faits ont été annulés", cause)
+	}
+
+	// Source actuelle de chaque renommage restant (un nom temporaire après rupture de cycle)
+	pending := make([]RenameMove, len(moves))
+	copy(pending, moves)
+	for len(pending) > 0 {
+		occupied := map[string]int{}
+		for i, m := range pending {
+			occupied[keys.key(m.Old)] = i
+		}
+
+		progressed := false
+		rest := pending[:0]
+		for i, m := range pending {
+			// La cible est encore une source en attente (autre que lui-même pour un changement de casse)
+			target := keys.key(m.New)
+			if j, ok := occupied[target]; ok && j != i {
+				rest = append(rest, m)
+				continue
+			}
+			// rename(2) écraserait sans prévenir un fichier apparu depuis la vérification
+			if target != keys.key(m.Old) && !freed[target] {
+				if _, err := os.Lstat(m.New); err == nil {
+					return rollback(fmt.Errorf("erreur sur %s: %s existe déjà", m.Old, m.New))
 				}
-				num := opts.StartNumber + i
-				name = fmt.Sprintf("%s_%0*d", opts.NewName, padding, num)
-			} else {
-				name = opts.NewName
 			}
-		} else {
-			// Remplacement texte
-			if opts.Replace != "" {
-				name = strings.ReplaceAll(name, opts.Replace, opts.With)
+			if err := rename(m.Old, m.New); err != nil {
+				return rollback(fmt.Errorf("erreur sur %s: %v", m.Old, err))
+			}
+			delete(occupied, keys.key(m.Old))
+			progressed = true
+		}
+		pending = rest
+
+		if !progressed && len(pending) > 0 {
+			// Cycle : le premier fichier passe par un nom temporaire
+			tmp, err := tempName(pending[0].Old)
+			if err == nil {
+				err = rename(pending[0].Old, tmp)
 			}
-			// Numérotation
-			if opts.StartNumber >= 0 {
-				num := opts.StartNumber + i
-				name = fmt.Sprintf("%s%0*d", opts.Prefix, opts.Padding, num)
-			} else {
-				// Ajouter préfixe/suffixe si pas de numérotation
-				name = opts.Prefix + name + opts.Suffix
+			if err != nil {
+				return rollback(fmt.Errorf("erreur sur %s: %v", pending[0].Old, err))
 			}
+			pending[0].Old = tmp
+		}
+	}
+	return nil
+}
+
+// tempName retourne un nom libre dans le dossier de path.
+func tempName(path string) (string, error) {
+	dir, base := filepath.Dir(path), filepath.Base(path)
+	for n := 0; n < 1000; n++ {
+		tmp := filepath.Join(dir, fmt.Sprintf(".%s.renommage-%d.tmp", base, n))
+		if _, err := os.Lstat(tmp); os.IsNotExist(err) {
+			return tmp, nil
 		}
+	}
+	return "", fmt.Errorf("aucun nom temporaire libre")
+}
+
+// PreviewRename calcule les renommages de RenameBatch sans toucher au disque.
+func PreviewRename(paths []string, opts OptionRename) *RenamePreview {
+	preview, _ := PreviewRecipe(paths, opts.recipe(len(paths))) // recette toujours valide
+	return preview
+}
+
+// PreviewRecipe calcule les renommages de la recette sans toucher au disque et signale les
+// conflits : source introuvable, cible existante, cible en double dans le lot, nom invalide.
+func PreviewRecipe(paths []string, recipe RenameRecipe) (*RenamePreview, error) {
+	if err := recipe.Validate(); err != nil {
+		return nil, err
+	}
+	preview := &RenamePreview{Pairs: make([]RenamePair, len(paths))}
+	keys := pathKeys{}
+	targets := map[string][]int{} // cible → indices du lot
+	sources := map[string]bool{}  // sources du lot
+	for _, oldPath := range paths {
+		sources[keys.key(oldPath)] = true
+	}
+
+	for i, oldPath := range paths {
+		name := recipe.fileName(oldPath, i)
+		newPath := filepath.Join(filepath.Dir(oldPath), name)
+		pair := RenamePair{Old: oldPath, New: newPath}
 
-		// Toujours garder l'extension
-		name += ext
-		newPath := filepath.Join(dir, name)
+		if _, err := os.Lstat(oldPath); err != nil {
+			pair.Conflicts = append(pair.Conflicts, "fichier introuvable")
+		}
+		if reason := invalidFileName(name); reason != "" {
+			pair.Conflicts = append(pair.Conflicts, reason)
+		}
+		key := keys.key(newPath)
+		targets[key] = append(targets[key], i)
 
-		// Vérifier si le fichier de destination existe déjà
-		if newPath != oldPath {
+		// Une cible existante n'est libre que si c'est le fichier lui-même (changement de
+		// casse) ou une autre source du lot, qui sera renommée avant
+		if newPath != oldPath && !samePath(oldPath, newPath) {
 			if _, err := os.Stat(newPath); err == nil {
-				return fmt.Errorf("le fichier %s existe déjà", newPath)
+				if _, ok := sources[key]; !ok {
+					pair.Conflicts = append(pair.Conflicts, "la cible existe déjà")
+				}
 			}
 		}
+		preview.Pairs[i] = pair
+	}
 
-		if err := os.Rename(oldPath, newPath); err != nil {
-			return fmt.Errorf("erreur sur %s: %v", oldPath, err)
+	for _, indices := range targets {
+		if len(indices) < 2 {
+			continue
+		}
+		for _, i := range indices {
+			preview.Pairs[i].Conflicts = append(preview.Pairs[i].Conflicts, fmt.Sprintf("cible identique pour %d fichiers du lot", len(indices)))
 		}
 	}
-	return nil
+	for _, p := range preview.Pairs {
+		if len(p.Conflicts) > 0 {
+			preview.Conflicts++
+		}
+	}
+	return preview, nil
+}
+
+// invalidFileName retourne la raison pour laquelle name n'est pas un nom de fichier valide, ou "".
+func invalidFileName(name string) string {
+	if name == "" || name == "." || name == ".." {
+		return "nom vide"
+	}
+	if i := strings.IndexAny(name, invalidNameChars); i >= 0 {
+		return fmt.Sprintf("caractère interdit '%c'", name[i])
+	}
+	for _, r := range name {
+		if r < 32 {
+			return "caractère de contrôle dans le nom"
+		}
+	}
+	if strings.HasSuffix(name, " ") || strings.HasSuffix(name, ".") {
+		return "le nom ne peut pas finir par un espace ou un point"
+	}
+	stem := strings.ToUpper(strings.TrimSuffix(name, filepath.Ext(name)))
+	if reservedNames[stem] {
+		return fmt.Sprintf("nom réservé par le système '%s'", stem)
+	}
+	return ""
+}
+
+// pathKeys donne la clé de comparaison des chemins : sans casse uniquement dans les dossiers
+// dont le système de fichiers ignore la casse, sondés une fois chacun.
+type pathKeys map[string]bool // dossier → insensible à la casse
+
+func (k pathKeys) key(path string) string {
+	dir := filepath.Dir(path)
+	fold, ok := k[dir]
+	if !ok {
+		fold = caseInsensitiveDir(dir)
+		k[dir] = fold
+	}
+	if fold {
+		return strings.ToLower(path)
+	}
+	return path
+}
+
+// caseInsensitiveDir crée un fichier témoin dans dir et regarde si son nom en majuscules
+// désigne le même fichier. Faute de pouvoir écrire, suit le comportement par défaut du système.
+func caseInsensitiveDir(dir string) bool {
+	f, err := os.CreateTemp(dir, ".casse-*.tmp")
+	if err != nil {
+		return runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+	}
+	name := f.Name()
+	f.Close()
+	defer os.Remove(name)
+
+	probe := filepath.Join(dir, strings.ToUpper(filepath.Base(name)))
+	return samePath(name, probe)
+}
+
+// samePath indique si a et b désignent le même fichier existant (système insensible à la casse).
+func samePath(a, b string) bool {
+	ia, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	ib, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ia, ib)
 }
@@ -0,0 +1,246 @@
+package files
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Types de règles de renommage
+const (
+	RuleReplace = "replace"
+	RuleInsert  = "insert"
+	RuleRemove  = "remove"
+	RulePrefix  = "prefix"
+	RuleSuffix  = "suffix"
+	RuleNumber  = "number"
+	RuleCase    = "case"
+	RuleTrim    = "trim"
+)
+
+// RenameRule est une étape de renommage, appliquée au nom sans son extension
+type RenameRule struct {
+	Type      string `json:"type"`                // replace, insert, remove, prefix, suffix, number, case ou trim
+	Find      string `json:"find,omitempty"`      // replace : texte (ou expression régulière) cherché
+	With      string `json:"with,omitempty"`      // replace : remplacement ($1… en expression régulière)
+	Regex     bool   `json:"regex,omitempty"`     // replace : Find est une expression régulière
+	Text      string `json:"text,omitempty"`      // insert, prefix, suffix : texte ajouté
+	Position  int    `json:"position,omitempty"`  // insert, remove : position en caractères (négative = depuis la fin)
+	Count     int    `json:"count,omitempty"`     // remove : nombre de caractères retirés (0 = jusqu'à la fin)
+	Start     int    `json:"start,omitempty"`     // number : numéro du premier fichier
+	Step      int    `json:"step,omitempty"`      // number : incrément entre deux fichiers (1 par défaut)
+	Padding   int    `json:"padding,omitempty"`   // number : nombre minimal de chiffres
+	Separator string `json:"separator,omitempty"` // number : séparateur entre le nom et le numéro
+	Placement string `json:"placement,omitempty"` // number : "suffix" (défaut), "prefix" ou "replace" (le nom devient le numéro)
+	Case      string `json:"case,omitempty"`      // case : "lower", "upper", "title" ou "sentence"
+	Chars     string `json:"chars,omitempty"`     // trim : caractères retirés aux extrémités (espaces par défaut)
+}
+
+// RenameRecipe est une suite ordonnée de règles, enregistrable en JSON pour être partagée
+type RenameRecipe struct {
+	Name  string       `json:"name,omitempty"`
+	Rules []RenameRule `json:"rules"`
+}
+
+// Validate vérifie chaque règle (type connu, expression régulière valide…).
+func (r RenameRecipe) Validate() error {
+	for i, rule := range r.Rules {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("règle %d (%s) : %w", i+1, rule.Type, err)
+		}
+	}
+	return nil
+}
+
+func (r RenameRule) validate() error {
+	switch r.Type {
+	case RuleReplace:
+		if r.Find == "" {
+			return fmt.Errorf("texte à remplacer vide")
+		}
+		if r.Regex {
+			if _, err := regexp.Compile(r.Find); err != nil {
+				return fmt.Errorf("expression régulière invalide : %w", err)
+			}
+		}
+	case RuleRemove:
+		if r.Count < 0 {
+			return fmt.Errorf("nombre de caractères négatif")
+		}
+	case RuleNumber:
+		switch r.Placement {
+		case "", "suffix", "prefix", "replace":
+		default:
+			return fmt.Errorf("placement '%s' inconnu", r.Placement)
+		}
+	case RuleCase:
+		switch r.Case {
+		case "lower", "upper", "title", "sentence":
+		default:
+			return fmt.Errorf("casse '%s' inconnue", r.Case)
+		}
+	case RuleInsert, RulePrefix, RuleSuffix, RuleTrim:
+	default:
+		return fmt.Errorf("type de règle inconnu")
+	}
+	return nil
+}
+
+// fileName applique les règles au i-ème fichier du lot ; l'extension est conservée.
+// La recette doit avoir été validée.
+func (r RenameRecipe) fileName(oldPath string, i int) string {
+	base := filepath.Base(oldPath)
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	for _, rule := range r.Rules {
+		name = rule.apply(name, i)
+	}
+	return name + ext
+}
+
+func (r RenameRule) apply(name string, i int) string {
+	switch r.Type {
+	case RuleReplace:
+		if r.Regex {
+			return regexp.MustCompile(r.Find).ReplaceAllString(name, r.With)
+		}
+		return strings.ReplaceAll(name, r.Find, r.With)
+
+	case RuleInsert:
+		runes := []rune(name)
+		at := runeIndex(len(runes), r.Position)
+		return string(runes[:at]) + r.Text + string(runes[at:])
+
+	case RuleRemove:
+		runes := []rune(name)
+		from := runeIndex(len(runes), r.Position)
+		to := len(runes)
+		if r.Count > 0 {
+			to = min(to, from+r.Count)
+		}
+		return string(runes[:from]) + string(runes[to:])
+
+	case RulePrefix:
+		return r.Text + name
+
+	case RuleSuffix:
+		return name + r.Text
+
+	case RuleNumber:
+		step := r.Step
+		if step == 0 {
+			step = 1
+		}
+		num := fmt.Sprintf("%0*d", r.Padding, r.Start+i*step)
+		switch r.Placement {
+		case "prefix":
+			return num + r.Separator + name
+		case "replace":
+			return num
+		}
+		return name + r.Separator + num
+
+	case RuleCase:
+		switch r.Case {
+		case "lower":
+			return strings.ToLower(name)
+		case "upper":
+			return strings.ToUpper(name)
+		case "sentence":
+			runes := []rune(strings.ToLower(name))
+			if len(runes) > 0 {
+				runes[0] = unicode.ToUpper(runes[0])
+			}
+			return string(runes)
+		}
+		// title : majuscule au début de chaque mot (séparés par espace, _, - ou .)
+		runes := []rune(strings.ToLower(name))
+		for k := range runes {
+			if k == 0 || strings.ContainsRune(" _-.", runes[k-1]) {
+				runes[k] = unicode.ToUpper(runes[k])
+			}
+		}
+		return string(runes)
+
+	case RuleTrim:
+		chars := r.Chars
+		if chars == "" {
+			chars = " \t"
+		}
+		return strings.Trim(name, chars)
+	}
+	return name
+}
+
+// runeIndex convertit une position (négative = depuis la fin) en indice borné à [0, n].
+func runeIndex(n, pos int) int {
+	if pos < 0 {
+		pos += n
+	}
+	return max(0, min(n, pos))
+}
+
+// recipe convertit les options historiques en règles, avec le même résultat.
+func (o OptionRename) recipe(count int) RenameRecipe {
+	var rules []RenameRule
+	switch {
+	case o.NewName != "" && count > 1:
+		padding := o.Padding
+		if padding == 0 {
+			padding = 3 // padding par défaut
+		}
+		rules = []RenameRule{
+			{Type: RuleNumber, Placement: "replace", Start: o.StartNumber, Padding: padding},
+			{Type: RulePrefix, Text: o.NewName + "_"},
+		}
+	case o.NewName != "":
+		rules = []RenameRule{{Type: RuleRemove}, {Type: RulePrefix, Text: o.NewName}}
+	default:
+		if o.Replace != "" {
+			rules = append(rules, RenameRule{Type: RuleReplace, Find: o.Replace, With: o.With})
+		}
+		if o.StartNumber >= 0 {
+			rules = append(rules,
+				RenameRule{Type: RuleNumber, Placement: "replace", Start: o.StartNumber, Padding: o.Padding},
+				RenameRule{Type: RulePrefix, Text: o.Prefix})
+		} else {
+			rules = append(rules, RenameRule{Type: RulePrefix, Text: o.Prefix}, RenameRule{Type: RuleSuffix, Text: o.Suffix})
+		}
+	}
+	return RenameRecipe{Rules: rules}
+}
+
+// SaveRecipe enregistre la recette en JSON pour la réutiliser ou la partager.
+func SaveRecipe(path string, recipe RenameRecipe) error {
+	if err := recipe.Validate(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(recipe, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("échec écriture '%s' : %w", path, err)
+	}
+	return nil
+}
+
+// LoadRecipe lit une recette enregistrée par SaveRecipe.
+func LoadRecipe(path string) (*RenameRecipe, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("échec lecture '%s' : %w", path, err)
+	}
+	var recipe RenameRecipe
+	if err := json.Unmarshal(data, &recipe); err != nil {
+		return nil, fmt.Errorf("recette de renommage invalide : %w", err)
+	}
+	if err := recipe.Validate(); err != nil {
+		return nil, err
+	}
+	return &recipe, nil
+}
@@ -0,0 +1,353 @@
+package images
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// AtlasOptions définit la construction d'un atlas de textures (sprite sheet)
+type AtlasOptions struct {
+	MaxWidth   int // taille maximale d'un atlas (2048 par défaut)
+	MaxHeight  int
+	Padding    int    // espace entre deux images, en pixels
+	Trim       bool   // retire les bords transparents de chaque image
+	PowerOfTwo bool   // dimensions des atlas en puissances de deux
+	Format     string // "png" (défaut) ou "webp" (sans perte)
+	Name       string // préfixe des fichiers générés ("atlas" par défaut)
+	CSS        bool   // génère aussi une feuille CSS avec une classe par image
+}
+
+// AtlasFrame décrit la position d'une image dans un atlas
+type AtlasFrame struct {
+	Name    string `json:"name"`
+	Source  string `json:"source"`
+	Atlas   int    `json:"atlas"`
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Trimmed bool   `json:"trimmed"`
+	OffsetX int    `json:"offset_x"` // décalage de la zone conservée dans l'image source
+	OffsetY int    `json:"offset_y"`
+	SourceW int    `json:"source_width"`
+	SourceH int    `json:"source_height"`
+}
+
+// AtlasSheet décrit un fichier atlas généré
+type AtlasSheet struct {
+	File   string `json:"file"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// AtlasResult est retourné et écrit dans le fichier JSON de l'atlas
+type AtlasResult struct {
+	Sheets []AtlasSheet `json:"sheets"`
+	Frames []AtlasFrame `json:"frames"`
+	JSON   string       `json:"json"`
+	CSS    string       `json:"css,omitempty"`
+}
+
+type atlasSprite struct {
+	frame AtlasFrame
+	img   image.Image
+	rect  image.Rectangle // zone conservée dans l'image source
+}
+
+// BuildAtlas regroupe les images dans un ou plusieurs atlas (algorithme MaxRects)
+// et écrit les atlas, la carte JSON et éventuellement la feuille CSS dans outputDir.
+func BuildAtlas(paths []string, outputDir string, opts AtlasOptions) (*AtlasResult, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("aucune image à assembler")
+	}
+	opts = atlasDefaults(opts)
+	format := strings.ToUpper(opts.Format)
+	if format != "PNG" && format != "WEBP" {
+		return nil, fmt.Errorf("format d'atlas '%s' non supporté (png ou webp)", opts.Format)
+	}
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return nil, fmt.Errorf("impossible de créer le dossier de sortie : %w", err)
+	}
+
+	// Chargement des images
+	sprites := make([]*atlasSprite, len(paths))
+	_, err := ParallelConvert(paths, func(i int, path string) ([]byte, error) {
+		img, err := decodeFile(path)
+		if err != nil {
+			return nil, err
+		}
+		r := img.Bounds()
+		if opts.Trim {
+			r = opaqueBounds(img)
+		}
+		sprites[i] = &atlasSprite{
+			img:  img,
+			rect: r,
+			frame: AtlasFrame{
+				Source:  path,
+				Width:   r.Dx(),
+				Height:  r.Dy(),
+				Trimmed: r != img.Bounds(),
+				OffsetX: r.Min.X - img.Bounds().Min.X,
+				OffsetY: r.Min.Y - img.Bounds().Min.Y,
+				SourceW: img.Bounds().Dx(),
+				SourceH: img.Bounds().Dy(),
+			},
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	assignFrameNames(sprites)
+
+	// Placement : les plus grandes images d'abord
+	order := make([]*atlasSprite, len(sprites))
+	copy(order, sprites)
+	sort.SliceStable(order, func(a, b int) bool {
+		sa := max(order[a].frame.Width, order[a].frame.Height)
+		sb := max(order[b].frame.Width, order[b].frame.Height)
+		return sa > sb
+	})
+
+	binW, binH := opts.MaxWidth, opts.MaxHeight
+	if opts.PowerOfTwo {
+		binW, binH = floorPowerOfTwo(binW), floorPowerOfTwo(binH)
+	}
+
+	var bins []*maxRectsBin
+	for _, s := range order {
+		w, h := s.frame.Width+opts.Padding, s.frame.Height+opts.Padding
+		if s.frame.Width > binW || s.frame.Height > binH {
+			return nil, fmt.Errorf("'%s' (%d×%d) dépasse la taille maximale de l'atlas", s.frame.Source, s.frame.Width, s.frame.Height)
+		}
+
+		placed := false
+		for i, bin := range bins {
+			if r, ok := bin.insert(w, h); ok {
+				s.frame.Atlas, s.frame.X, s.frame.Y = i, r.Min.X, r.Min.Y
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			// Le padding final peut dépasser le bord : la case accepte donc padding en plus
+			bin := newMaxRectsBin(binW+opts.Padding, binH+opts.Padding)
+			r, _ := bin.insert(w, h)
+			bins = append(bins, bin)
+			s.frame.Atlas, s.frame.X, s.frame.Y = len(bins)-1, r.Min.X, r.Min.Y
+		}
+	}
+
+	// Rendu et écriture de chaque atlas
+	result := &AtlasResult{}
+	ext := strings.ToLower(opts.Format)
+	for i := range bins {
+		w, h := 1, 1
+		for _, s := range sprites {
+			if s.frame.Atlas == i {
+				w = max(w, s.frame.X+s.frame.Width)
+				h = max(h, s.frame.Y+s.frame.Height)
+			}
+		}
+		if opts.PowerOfTwo {
+			w, h = ceilPowerOfTwo(w), ceilPowerOfTwo(h)
+		}
+
+		sheet := image.NewNRGBA(image.Rect(0, 0, w, h))
+		for _, s := range sprites {
+			if s.frame.Atlas != i {
+				continue
+			}
+			dst := image.Rect(s.frame.X, s.frame.Y, s.frame.X+s.frame.Width, s.frame.Y+s.frame.Height)
+			draw.Draw(sheet, dst, s.img, s.rect.Min, draw.Src)
+		}
+
+		data, err := encodeImage(sheet, nil, format, &Options{Format: format, Lossless: true, Quality: 100})
+		if err != nil {
+			return nil, err
+		}
+		name := fmt.Sprintf("%s-%d.%s", opts.Name, i, ext)
+		if err := os.WriteFile(filepath.Join(outputDir, name), data, 0o644); err != nil {
+			return nil, fmt.Errorf("échec écriture '%s' : %w", name, err)
+		}
+		result.Sheets = append(result.Sheets, AtlasSheet{File: name, Width: w, Height: h})
+	}
+
+	for _, s := range sprites {
+		result.Frames = append(result.Frames, s.frame)
+	}
+
+	if opts.CSS {
+		result.CSS = filepath.Join(outputDir, opts.Name+".css")
+		if err := os.WriteFile(result.CSS, []byte(atlasCSS(result)), 0o644); err != nil {
+			return nil, fmt.Errorf("échec écriture de la feuille CSS : %w", err)
+		}
+	}
+
+	result.JSON = filepath.Join(outputDir, opts.Name+".json")
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(result.JSON, data, 0o644); err != nil {
+		return nil, fmt.Errorf("échec écriture de la carte JSON : %w", err)
+	}
+
+	return result, nil
+}
+
+func atlasDefaults(opts AtlasOptions) AtlasOptions {
+	if opts.MaxWidth <= 0 {
+		opts.MaxWidth = 2048
+	}
+	if opts.MaxHeight <= 0 {
+		opts.MaxHeight = 2048
+	}
+	if opts.Padding < 0 {
+		opts.Padding = 0
+	}
+	if opts.Format == "" {
+		opts.Format = "png"
+	}
+	if opts.Name == "" {
+		opts.Name = "atlas"
+	}
+	return opts
+}
+
+// opaqueBounds renvoie le plus petit rectangle contenant tous les pixels non transparents.
+func opaqueBounds(img image.Image) image.Rectangle {
+	b := img.Bounds()
+	r := image.Rectangle{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0 {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if r.Empty() {
+		// Image entièrement transparente : on garde un pixel
+		return image.Rect(b.Min.X, b.Min.Y, b.Min.X+1, b.Min.Y+1)
+	}
+	return r
+}
+
+// assignFrameNames nomme chaque image d'après son fichier, en évitant les doublons.
+func assignFrameNames(sprites []*atlasSprite) {
+	used := map[string]int{}
+	for _, s := range sprites {
+		base := filepath.Base(s.frame.Source)
+		name := strings.TrimSuffix(base, filepath.Ext(base))
+		used[name]++
+		if n := used[name]; n > 1 {
+			name = fmt.Sprintf("%s_%d", name, n)
+		}
+		s.frame.Name = name
+	}
+}
+
+var cssClassInvalid = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// atlasCSS génère une classe par image avec la position dans son atlas.
+func atlasCSS(res *AtlasResult) string {
+	var sb strings.Builder
+	for _, f := range res.Frames {
+		class := cssClassInvalid.ReplaceAllString(f.Name, "-")
+		fmt.Fprintf(&sb, ".sprite-%s {\n", class)
+		fmt.Fprintf(&sb, "  background: url(\"%s\") no-repeat -%dpx -%dpx;\n", res.Sheets[f.Atlas].File, f.X, f.Y)
+		fmt.Fprintf(&sb, "  width: %dpx;\n  height: %dpx;\n}\n\n", f.Width, f.Height)
+	}
+	return sb.String()
+}
+
+func floorPowerOfTwo(v int) int {
+	p := 1
+	for p*2 <= v {
+		p *= 2
+	}
+	return p
+}
+
+func ceilPowerOfTwo(v int) int {
+	p := 1
+	for p < v {
+		p *= 2
+	}
+	return p
+}
+
+// maxRectsBin implémente l'algorithme MaxRects (heuristique « best short side fit »).
+type maxRectsBin struct {
+	free []image.Rectangle
+}
+
+func newMaxRectsBin(w, h int) *maxRectsBin {
+	return &maxRectsBin{free: []image.Rectangle{image.Rect(0, 0, w, h)}}
+}
+
+// insert place un rectangle w×h et renvoie sa position.
+func (b *maxRectsBin) insert(w, h int) (image.Rectangle, bool) {
+	best := -1
+	bestShort, bestLong := 0, 0
+	for i, f := range b.free {
+		if f.Dx() < w || f.Dy() < h {
+			continue
+		}
+		dw, dh := f.Dx()-w, f.Dy()-h
+		short, long := min(dw, dh), max(dw, dh)
+		if best < 0 || short < bestShort || (short == bestShort && long < bestLong) {
+			best, bestShort, bestLong = i, short, long
+		}
+	}
+	if best < 0 {
+		return image.Rectangle{}, false
+	}
+
+	placed := image.Rect(b.free[best].Min.X, b.free[best].Min.Y, b.free[best].Min.X+w, b.free[best].Min.Y+h)
+
+	// Découpe des zones libres qui chevauchent le rectangle placé
+	var next []image.Rectangle
+	for _, f := range b.free {
+		if !f.Overlaps(placed) {
+			next = append(next, f)
+			continue
+		}
+		if placed.Min.X > f.Min.X {
+			next = append(next, image.Rect(f.Min.X, f.Min.Y, placed.Min.X, f.Max.Y))
+		}
+		if placed.Max.X < f.Max.X {
+			next = append(next, image.Rect(placed.Max.X, f.Min.Y, f.Max.X, f.Max.Y))
+		}
+		if placed.Min.Y > f.Min.Y {
+			next = append(next, image.Rect(f.Min.X, f.Min.Y, f.Max.X, placed.Min.Y))
+		}
+		if placed.Max.Y < f.Max.Y {
+			next = append(next, image.Rect(f.Min.X, placed.Max.Y, f.Max.X, f.Max.Y))
+		}
+	}
+
+	// Suppression des zones contenues dans une autre
+	b.free = b.free[:0]
+	for i, r := range next {
+		contained := false
+		for j, o := range next {
+			if i != j && r.In(o) && (r != o || j < i) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			b.free = append(b.free, r)
+		}
+	}
+	return placed, true
+}
@@ -0,0 +1,540 @@
+package images
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// CodePoint est un point en pixels dans l'image analysée
+type CodePoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// ScannedCode est un code trouvé dans une image
+type ScannedCode struct {
+	Type    string      `json:"type"` // "qr", "code128" ou "ean13"
+	Content string      `json:"content"`
+	Points  []CodePoint `json:"points"` // coins du code : haut-gauche, haut-droit, bas-droit, bas-gauche
+}
+
+// CodeScan regroupe les codes lus dans un fichier
+type CodeScan struct {
+	Source string        `json:"source"`
+	Codes  []ScannedCode `json:"codes"`
+	Error  string        `json:"error,omitempty"` // fichier illisible, les autres sont quand même analysés
+}
+
+// ReadCodesFromFiles cherche les QR codes et codes-barres (Code 128, EAN-13) dans chaque image.
+// Un fichier illisible est signalé dans son résultat sans interrompre le lot.
+func ReadCodesFromFiles(paths []string) ([]CodeScan, error) {
+	scans := make([]CodeScan, len(paths))
+	_, err := ParallelConvert(paths, func(i int, path string) ([]byte, error) {
+		scans[i] = CodeScan{Source: path, Codes: []ScannedCode{}}
+		img, err := decodeFile(path)
+		if err != nil {
+			scans[i].Error = err.Error()
+			return nil, nil
+		}
+		scans[i].Codes = ReadCodes(img)
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return scans, nil
+}
+
+// ReadCodes retourne les codes trouvés dans img. Deux seuillages sont essayés (local puis
+// global) pour tolérer les photos mal éclairées comme les images générées.
+// Les QR codes sont lus quelle que soit leur rotation, les codes-barres à l'horizontale ou
+// à la verticale (à quelques degrés près).
+func ReadCodes(img image.Image) []ScannedCode {
+	lum := newLuminance(img)
+	codes := []ScannedCode{}
+	seen := map[string]bool{}
+	add := func(found []ScannedCode) {
+		for _, c := range found {
+			if key := c.Type + "\x00" + c.Content; !seen[key] {
+				seen[key] = true
+				codes = append(codes, c)
+			}
+		}
+	}
+
+	for _, bm := range []*bitMatrix{lum.localThreshold(), lum.globalThreshold()} {
+		add(findQRCodes(bm))
+		add(findLinearCodes(bm))
+	}
+
+	b := img.Bounds()
+	for i := range codes {
+		for j := range codes[i].Points {
+			codes[i].Points[j].X += b.Min.X
+			codes[i].Points[j].Y += b.Min.Y
+		}
+	}
+	return codes
+}
+
+// luminance est l'image en niveaux de gris (0–255), les zones transparentes comptées blanches
+type luminance struct {
+	w, h int
+	pix  []uint8
+}
+
+func newLuminance(img image.Image) *luminance {
+	b := img.Bounds()
+	l := &luminance{w: b.Dx(), h: b.Dy(), pix: make([]uint8, b.Dx()*b.Dy())}
+	for y := 0; y < l.h; y++ {
+		for x := 0; x < l.w; x++ {
+			r, g, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			// Composition sur fond blanc
+			white := 0xffff - a
+			v := (299*(r+white) + 587*(g+white) + 114*(bl+white)) / 1000
+			l.pix[y*l.w+x] = uint8(min(v, 0xffff) >> 8)
+		}
+	}
+	return l
+}
+
+// localThreshold compare chaque pixel à la moyenne de son voisinage (image intégrale),
+// ce qui supporte les ombres et les dégradés d'éclairage.
+func (l *luminance) localThreshold() *bitMatrix {
+	bm := newBitMatrix(l.w, l.h)
+	stride := l.w + 1
+	integral := make([]uint64, stride*(l.h+1)) // uint64 : les sommes dépassent 32 bits sur les grandes images
+	for y := 0; y < l.h; y++ {
+		var row uint64
+		for x := 0; x < l.w; x++ {
+			row += uint64(l.pix[y*l.w+x])
+			integral[(y+1)*stride+x+1] = integral[y*stride+x+1] + row
+		}
+	}
+
+	r := max(8, max(l.w, l.h)/16)
+	for y := 0; y < l.h; y++ {
+		y0, y1 := max(0, y-r), min(l.h, y+r+1)
+		for x := 0; x < l.w; x++ {
+			x0, x1 := max(0, x-r), min(l.w, x+r+1)
+			sum := integral[y1*stride+x1] - integral[y0*stride+x1] - integral[y1*stride+x0] + integral[y0*stride+x0]
+			count := uint64((x1 - x0) * (y1 - y0))
+			v := uint64(l.pix[y*l.w+x])
+			// Sombre : nettement sous la moyenne locale, ou presque noir
+			bm.bits[y*l.w+x] = v*10*count < sum*9 || v < 16
+		}
+	}
+	return bm
+}
+
+// globalThreshold applique un seuil unique calculé par la méthode d'Otsu.
+func (l *luminance) globalThreshold() *bitMatrix {
+	var hist [256]int
+	for _, v := range l.pix {
+		hist[v]++
+	}
+	total := len(l.pix)
+	var sumAll float64
+	for v, n := range hist {
+		sumAll += float64(v * n)
+	}
+
+	var sumBack float64
+	var back int
+	threshold, best := 128, -1.0
+	for t := 0; t < 256; t++ {
+		back += hist[t]
+		if back == 0 {
+			continue
+		}
+		front := total - back
+		if front == 0 {
+			break
+		}
+		sumBack += float64(t * hist[t])
+		mb := sumBack / float64(back)
+		mf := (sumAll - sumBack) / float64(front)
+		if between := float64(back) * float64(front) * (mb - mf) * (mb - mf); between > best {
+			best, threshold = between, t
+		}
+	}
+
+	bm := newBitMatrix(l.w, l.h)
+	for i, v := range l.pix {
+		bm.bits[i] = int(v) <= threshold
+	}
+	return bm
+}
+
+// bitMatrix est une image binarisée : true = module sombre
+type bitMatrix struct {
+	w, h int
+	bits []bool
+}
+
+func newBitMatrix(w, h int) *bitMatrix {
+	return &bitMatrix{w: w, h: h, bits: make([]bool, w*h)}
+}
+
+func (m *bitMatrix) get(x, y int) bool {
+	return x >= 0 && y >= 0 && x < m.w && y < m.h && m.bits[y*m.w+x]
+}
+
+// transpose échange lignes et colonnes, pour lire les codes-barres verticaux.
+func (m *bitMatrix) transpose() *bitMatrix {
+	t := newBitMatrix(m.h, m.w)
+	for y := 0; y < m.h; y++ {
+		for x := 0; x < m.w; x++ {
+			t.bits[x*t.w+y] = m.bits[y*m.w+x]
+		}
+	}
+	return t
+}
+
+// rowRuns découpe la ligne y en plages alternées, en commençant par une plage claire
+// (éventuellement vide) : les indices impairs sont les barres.
+func (m *bitMatrix) rowRuns(y int) []int {
+	runs := []int{0}
+	dark := false
+	for x := 0; x < m.w; x++ {
+		if v := m.bits[y*m.w+x]; v != dark {
+			runs = append(runs, 0)
+			dark = v
+		}
+		runs[len(runs)-1]++
+	}
+	return runs
+}
+
+// Largeurs des motifs Code 128 (barre, espace, barre…), valeurs 0 à 105 puis début du stop
+var code128Patterns = [107]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "233111",
+}
+
+// Largeurs des chiffres EAN en jeu L (espace, barre, espace, barre) ; le jeu R a les mêmes
+// largeurs en commençant par une barre, le jeu G les largeurs inversées.
+var eanPatterns = [10]string{"3211", "2221", "2122", "1411", "1132", "1231", "1114", "1312", "1213", "3112"}
+
+// Jeux L/G des six premiers chiffres (bit à 1 = G), indexés par le chiffre implicite
+var eanParity = [10]int{0b000000, 0b001011, 0b001101, 0b001110, 0b010011, 0b011001, 0b011100, 0b010101, 0b010110, 0b011010}
+
+// linearHit est une lecture sur une ligne
+type linearHit struct {
+	typ, content string
+	x0, x1       int
+}
+
+// findLinearCodes lit les codes 1D sur des lignes réparties dans l'image, à l'horizontale et
+// à la verticale, dans les deux sens. Un code doit être lu sur au moins deux lignes.
+func findLinearCodes(bm *bitMatrix) []ScannedCode {
+	type box struct {
+		typ, content          string
+		x0, y0, x1, y1, count int
+	}
+	boxes := map[string]*box{}
+	var order []string
+
+	for _, vertical := range []bool{false, true} {
+		m := bm
+		if vertical {
+			m = bm.transpose()
+		}
+		step := max(1, m.h/64)
+		for y := step / 2; y < m.h; y += step {
+			for _, hit := range scanLinearRow(m.rowRuns(y), m.w) {
+				key := hit.typ + "\x00" + hit.content
+				b, ok := boxes[key]
+				if !ok {
+					b = &box{typ: hit.typ, content: hit.content, x0: math.MaxInt, y0: math.MaxInt}
+					boxes[key] = b
+					order = append(order, key)
+				}
+				x0, y0, x1, y1 := hit.x0, y, hit.x1, y+1
+				if vertical {
+					x0, y0, x1, y1 = y, hit.x0, y+1, hit.x1
+				}
+				b.x0, b.y0 = min(b.x0, x0), min(b.y0, y0)
+				b.x1, b.y1 = max(b.x1, x1), max(b.y1, y1)
+				b.count++
+			}
+		}
+	}
+
+	codes := []ScannedCode{}
+	for _, key := range order {
+		b := boxes[key]
+		if b.count < 2 {
+			continue
+		}
+		codes = append(codes, ScannedCode{Type: b.typ, Content: b.content, Points: []CodePoint{
+			{b.x0, b.y0}, {b.x1, b.y0}, {b.x1, b.y1}, {b.x0, b.y1},
+		}})
+	}
+	return codes
+}
+
+// scanLinearRow cherche les codes d'une ligne, de gauche à droite puis de droite à gauche.
+func scanLinearRow(runs []int, width int) []linearHit {
+	var hits []linearHit
+	for _, reversed := range []bool{false, true} {
+		r := runs
+		if reversed {
+			r = make([]int, 0, len(runs)+1)
+			if len(runs)%2 == 0 {
+				r = append(r, 0) // la ligne finit sur une barre : plage claire vide en tête
+			}
+			for k := len(runs) - 1; k >= 0; k-- {
+				r = append(r, runs[k])
+			}
+		}
+
+		starts := make([]int, len(r)+1)
+		for i, n := range r {
+			starts[i+1] = starts[i] + n
+		}
+		for i := 1; i < len(r); i += 2 {
+			content, typ, end := "", "", 0
+			if c, ok := decodeEAN13(r, i); ok {
+				content, typ, end = c, CodeEAN13, i+59
+			} else if c, n, ok := decodeCode128(r, i); ok {
+				content, typ, end = c, CodeCode128, i+n
+			} else {
+				continue
+			}
+			x0, x1 := starts[i], starts[end]
+			if reversed {
+				x0, x1 = width-x1, width-x0
+			}
+			hits = append(hits, linearHit{typ: typ, content: content, x0: x0, x1: x1})
+			i = end - 1
+		}
+	}
+	return hits
+}
+
+// matchPattern retourne l'écart moyen par module entre les plages et le motif attendu, ou
+// +Inf si une plage s'en éloigne de plus de 0,7 module.
+func matchPattern(runs []int, pattern string, modules int) float64 {
+	total := 0
+	for _, n := range runs {
+		total += n
+	}
+	if total == 0 {
+		return math.Inf(1)
+	}
+	unit := float64(total) / float64(modules)
+	var variance float64
+	for i, n := range runs {
+		d := math.Abs(float64(n)/unit - float64(pattern[i]-'0'))
+		if d > 0.7 {
+			return math.Inf(1)
+		}
+		variance += d
+	}
+	return variance / float64(modules)
+}
+
+// bestPattern retourne l'indice du motif le plus proche, -1 si aucun n'est acceptable.
+func bestPattern(runs []int, patterns []string, modules int, maxVariance float64) int {
+	best, bestVar := -1, maxVariance
+	for i, p := range patterns {
+		if v := matchPattern(runs, p, modules); v < bestVar {
+			best, bestVar = i, v
+		}
+	}
+	return best
+}
+
+// quietBefore vérifie la marge claire avant la plage i (le bord de l'image en tient lieu).
+func quietBefore(runs []int, i int, unit float64) bool {
+	return i == 1 && runs[0] == 0 || float64(runs[i-1]) >= 3*unit
+}
+
+// decodeEAN13 lit un EAN-13 dont la garde de début commence à la barre runs[i].
+func decodeEAN13(runs []int, i int) (string, bool) {
+	if i+59 > len(runs) {
+		return "", false
+	}
+	total := 0
+	for _, n := range runs[i : i+59] {
+		total += n
+	}
+	unit := float64(total) / 95
+	if !quietBefore(runs, i, unit) || (i+59 < len(runs) && float64(runs[i+59]) < 3*unit) {
+		return "", false
+	}
+	for _, g := range [][2]int{{i, 3}, {i + 27, 5}, {i + 56, 3}} {
+		if matchPattern(runs[g[0]:g[0]+g[1]], "11111"[:g[1]], g[1]) > 0.3 {
+			return "", false
+		}
+	}
+
+	reversed := make([]string, 10)
+	for d, p := range eanPatterns {
+		reversed[d] = string([]byte{p[3], p[2], p[1], p[0]})
+	}
+	patterns := append(eanPatterns[:], reversed...) // 0–9 : L, 10–19 : G
+
+	digits := make([]byte, 13)
+	parity := 0
+	for d := 0; d < 6; d++ {
+		k := i + 3 + 4*d
+		v := bestPattern(runs[k:k+4], patterns, 7, 0.48)
+		if v < 0 {
+			return "", false
+		}
+		if v >= 10 {
+			parity |= 1 << (5 - d)
+		}
+		digits[d+1] = byte('0' + v%10)
+	}
+	for d := 0; d < 6; d++ {
+		k := i + 32 + 4*d
+		v := bestPattern(runs[k:k+4], eanPatterns[:], 7, 0.48)
+		if v < 0 {
+			return "", false
+		}
+		digits[d+7] = byte('0' + v)
+	}
+
+	first := -1
+	for d, p := range eanParity {
+		if p == parity {
+			first = d
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+	digits[0] = byte('0' + first)
+
+	sum := 0
+	for k := 0; k < 12; k++ {
+		w := 1
+		if k%2 == 1 {
+			w = 3
+		}
+		sum += int(digits[k]-'0') * w
+	}
+	if (10-sum%10)%10 != int(digits[12]-'0') {
+		return "", false
+	}
+	return string(digits), true
+}
+
+// decodeCode128 lit un Code 128 dont le symbole de début commence à la barre runs[i].
+// Retourne aussi le nombre de plages lues, stop compris.
+func decodeCode128(runs []int, i int) (string, int, bool) {
+	if i+6 > len(runs) {
+		return "", 0, false
+	}
+	start := bestPattern(runs[i:i+6], code128Patterns[:], 11, 0.25)
+	if start < 103 || start > 105 {
+		return "", 0, false
+	}
+	if !quietBefore(runs, i, float64(runsSum(runs[i:i+6]))/11) {
+		return "", 0, false
+	}
+
+	values := []int{start}
+	k := i + 6
+	for {
+		if k+6 > len(runs) {
+			return "", 0, false
+		}
+		v := bestPattern(runs[k:k+6], code128Patterns[:], 11, 0.25)
+		if v < 0 || v >= 103 && v <= 105 {
+			return "", 0, false
+		}
+		if v == 106 {
+			// Barre finale du stop (2 modules)
+			if k+7 > len(runs) {
+				return "", 0, false
+			}
+			unit := float64(runsSum(runs[k:k+6])) / 11
+			if math.Abs(float64(runs[k+6])/unit-2) > 0.7 {
+				return "", 0, false
+			}
+			k += 7
+			break
+		}
+		values = append(values, v)
+		k += 6
+	}
+	if len(values) < 3 {
+		return "", 0, false
+	}
+
+	check := values[len(values)-1]
+	data := values[:len(values)-1]
+	sum := data[0]
+	for p, v := range data[1:] {
+		sum += (p + 1) * v
+	}
+	if sum%103 != check {
+		return "", 0, false
+	}
+	text, ok := code128Text(data)
+	return text, k - i, ok
+}
+
+func runsSum(runs []int) int {
+	total := 0
+	for _, n := range runs {
+		total += n
+	}
+	return total
+}
+
+// code128Text convertit les valeurs (symbole de début compris) en texte selon les jeux A, B et C.
+func code128Text(values []int) (string, bool) {
+	set := values[0] - 103 // 0 = A, 1 = B, 2 = C
+	var out []byte
+	shift := false
+	for _, v := range values[1:] {
+		current := set
+		if shift {
+			current = 1 - set
+			shift = false
+		}
+		switch current {
+		case 2:
+			switch {
+			case v < 100:
+				out = append(out, byte('0'+v/10), byte('0'+v%10))
+			case v == 100:
+				set = 1
+			case v == 101:
+				set = 0
+			}
+		default:
+			switch {
+			case v < 64 || current == 1 && v < 96:
+				out = append(out, byte(v+32))
+			case v < 96:
+				out = append(out, byte(v-64)) // jeu A : caractères de contrôle
+			case v == 98:
+				shift = true
+			case v == 99:
+				set = 2
+			case v == 100 && current == 0, v == 101 && current == 1:
+				set = 1 - current
+			}
+		}
+		// FNC1 à FNC4 ignorés
+	}
+	return string(out), len(out) > 0
+}
+
+var errNoCode = fmt.Errorf("aucun code lisible")
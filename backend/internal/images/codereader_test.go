@@ -0,0 +1,98 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+// generatedCode produit un code avec GenerateCode et le relit comme image.
+func generatedCode(t *testing.T, opts CodeOptions) (image.Image, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "code.png")
+	res, err := GenerateCode(opts, path)
+	if err != nil {
+		t.Fatalf("GenerateCode : %v", err)
+	}
+	img, err := decodeFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img, res.Content
+}
+
+func TestReadCodesRoundTrip(t *testing.T) {
+	white := color.NRGBA{255, 255, 255, 255}
+	cases := []struct {
+		name    string
+		opts    CodeOptions
+		angles  []float64
+		codeTyp string
+	}{
+		{"qr-v1", CodeOptions{Type: CodeQR, Content: "HELLO", ModuleSize: 6},
+			[]float64{0, 17, 45, 90, 135, 180, 225, 270, 315}, CodeQR},
+		{"qr-v7", CodeOptions{Type: CodeQR, Content: "https://example.org/un/chemin/assez/long/pour/une/version/sept/ou/plus?id=1234567890", ModuleSize: 4},
+			[]float64{0, 30, 45, 90}, CodeQR},
+		{"qr-kanji", CodeOptions{Type: CodeQR, Content: "点茗", ModuleSize: 6},
+			[]float64{0, 45}, CodeQR},
+		{"code128", CodeOptions{Type: CodeCode128, Content: "ABC-12345"},
+			[]float64{0, 90, 180, 270}, CodeCode128},
+		{"ean13", CodeOptions{Type: CodeEAN13, Content: "400638133393"},
+			[]float64{0, 90, 180, 270}, CodeEAN13},
+	}
+	for _, c := range cases {
+		img, want := generatedCode(t, c.opts)
+		for _, angle := range c.angles {
+			rotated := img
+			if angle != 0 {
+				rotated = rotateAny(img, angle, white, true)
+			}
+			codes := ReadCodes(rotated)
+			found := false
+			for _, code := range codes {
+				if code.Type == c.codeTyp && code.Content == want {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("%s à %g° : %q attendu, lu %+v", c.name, angle, want, codes)
+			}
+		}
+	}
+}
+
+func TestReadCodesBlank(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 200, 200))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	if codes := ReadCodes(img); len(codes) != 0 {
+		t.Fatalf("aucun code attendu, lu %+v", codes)
+	}
+}
+
+// Sur plus de ~10 000 px de côté, les sommes du seuillage local dépassent 32 bits.
+func TestLocalThresholdLargeImage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("image de 14 Mpx")
+	}
+	img := image.NewGray(image.Rect(0, 0, 11000, 1320))
+	for i := range img.Pix {
+		img.Pix[i] = 240
+	}
+	for y := 640; y < 680; y++ {
+		for x := 5000; x < 5040; x++ {
+			img.Pix[y*img.Stride+x] = 10
+		}
+	}
+	bm := newLuminance(img).localThreshold()
+	if !bm.get(5020, 660) {
+		t.Error("pixel sombre lu comme clair")
+	}
+	for _, p := range []image.Point{{100, 10}, {5020, 660 - 100}, {5500, 700}, {10900, 1300}} {
+		if bm.get(p.X, p.Y) {
+			t.Errorf("pixel clair %v lu comme sombre", p)
+		}
+	}
+}
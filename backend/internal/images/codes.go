@@ -0,0 +1,322 @@
+package images
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/ean"
+	"github.com/boombuler/barcode/qr"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	CodeQR      = "qr"
+	CodeCode128 = "code128"
+	CodeEAN13   = "ean13"
+)
+
+// CodeOptions définit le code à générer
+type CodeOptions struct {
+	Type            string  // "qr" (défaut), "code128" ou "ean13"
+	Content         string  // texte à encoder (12 ou 13 chiffres en EAN-13)
+	ErrorCorrection string  // QR : "L", "M" (défaut), "Q" ou "H"
+	ModuleSize      int     // taille d'un module en pixels (8 en QR, 3 en 1D par défaut)
+	QuietZone       int     // marge blanche en modules (4 en QR, 10 en 1D par défaut, négatif = aucune)
+	BarHeight       int     // codes 1D : hauteur des barres en pixels (80 par défaut)
+	ShowText        bool    // codes 1D : affiche le contenu sous les barres
+	Foreground      string  // couleur des modules (#RRGGBB, noir par défaut)
+	Background      string  // couleur du fond (#RRGGBB[AA], blanc par défaut)
+	Logo            string  // QR : image placée au centre (préférer la correction "H")
+	LogoSize        float64 // QR : largeur du logo rapportée à celle du code (0.2 par défaut, 0.3 au maximum)
+	Format          string  // "png" (défaut), "svg", "jpeg" ou "webp"
+}
+
+// CodeResult décrit le fichier généré
+type CodeResult struct {
+	File    string `json:"file"`
+	Type    string `json:"type"`
+	Content string `json:"content"` // contenu encodé (avec la clé de contrôle en EAN-13)
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Modules int    `json:"modules"` // largeur du code en modules, marge comprise
+}
+
+// codeLayout est le code à dessiner : une grille de modules (une seule ligne pour les codes 1D)
+type codeLayout struct {
+	opts          CodeOptions
+	modules       [][]bool
+	quiet         int
+	fg, bg        color.NRGBA
+	width, height int // en pixels
+	text          string
+	textHeight    int
+}
+
+// GenerateCode produit un QR code ou un code-barres et l'écrit dans output.
+func GenerateCode(opts CodeOptions, output string) (*CodeResult, error) {
+	opts.Type = strings.ToLower(opts.Type)
+	if opts.Type == "" {
+		opts.Type = CodeQR
+	}
+	if opts.Content == "" {
+		return nil, fmt.Errorf("aucun contenu à encoder")
+	}
+	if opts.Format == "" {
+		opts.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(output)), ".")
+		if opts.Format == "" {
+			opts.Format = "png"
+		}
+	}
+	format := strings.ToUpper(opts.Format)
+
+	bc, err := encodeCode(opts)
+	if err != nil {
+		return nil, err
+	}
+	l, err := newCodeLayout(bc, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var logo image.Image
+	if opts.Logo != "" {
+		if opts.Type != CodeQR {
+			return nil, fmt.Errorf("le logo central n'est possible que sur un QR code")
+		}
+		if logo, err = decodeFile(opts.Logo); err != nil {
+			return nil, err
+		}
+	}
+
+	var data []byte
+	if format == "SVG" {
+		data, err = l.svg(logo)
+	} else {
+		encOpts := applyDefaults(&Options{Format: format, Quality: 95})
+		data, err = encodeImage(l.raster(logo), nil, format, encOpts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := writeOutputFile(output, data); err != nil {
+		return nil, err
+	}
+
+	return &CodeResult{
+		File:    output,
+		Type:    opts.Type,
+		Content: bc.Content(),
+		Width:   l.width,
+		Height:  l.height,
+		Modules: len(l.modules[0]) + 2*l.quiet,
+	}, nil
+}
+
+func encodeCode(opts CodeOptions) (barcode.Barcode, error) {
+	switch opts.Type {
+	case CodeQR:
+		levels := map[string]qr.ErrorCorrectionLevel{"": qr.M, "L": qr.L, "M": qr.M, "Q": qr.Q, "H": qr.H}
+		level, ok := levels[strings.ToUpper(opts.ErrorCorrection)]
+		if !ok {
+			return nil, fmt.Errorf("niveau de correction '%s' invalide (L, M, Q ou H)", opts.ErrorCorrection)
+		}
+		bc, err := qr.Encode(opts.Content, level, qr.Auto)
+		if err != nil {
+			return nil, fmt.Errorf("encodage QR impossible : %w", err)
+		}
+		return bc, nil
+
+	case CodeCode128:
+		bc, err := code128.Encode(opts.Content)
+		if err != nil {
+			return nil, fmt.Errorf("encodage Code 128 impossible : %w", err)
+		}
+		return bc, nil
+
+	case CodeEAN13:
+		if n := len(opts.Content); (n != 12 && n != 13) || strings.Trim(opts.Content, "0123456789") != "" {
+			return nil, fmt.Errorf("un EAN-13 attend 12 ou 13 chiffres")
+		}
+		bc, err := ean.Encode(opts.Content)
+		if err != nil {
+			return nil, fmt.Errorf("encodage EAN-13 impossible : %w", err)
+		}
+		return bc, nil
+	}
+	return nil, fmt.Errorf("type de code '%s' non supporté", opts.Type)
+}
+
+func newCodeLayout(bc barcode.Barcode, opts CodeOptions) (*codeLayout, error) {
+	fg, err := parseHexColor(opts.Foreground, color.NRGBA{A: 255})
+	if err != nil {
+		return nil, err
+	}
+	bg, err := parseHexColor(opts.Background, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+	if err != nil {
+		return nil, err
+	}
+
+	// Modules lus sur le code non agrandi (1 pixel = 1 module)
+	b := bc.Bounds()
+	modules := make([][]bool, b.Dy())
+	for y := range modules {
+		modules[y] = make([]bool, b.Dx())
+		for x := range modules[y] {
+			r, _, _, _ := bc.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			modules[y][x] = r < 0x8000
+		}
+	}
+
+	twoD := opts.Type == CodeQR
+	if opts.ModuleSize <= 0 {
+		opts.ModuleSize = 3
+		if twoD {
+			opts.ModuleSize = 8
+		}
+	}
+	quiet := opts.QuietZone
+	if quiet == 0 {
+		quiet = 10
+		if twoD {
+			quiet = 4
+		}
+	}
+	quiet = max(0, quiet)
+
+	l := &codeLayout{opts: opts, modules: modules, quiet: quiet, fg: fg, bg: bg, text: bc.Content()}
+	cols := len(modules[0]) + 2*quiet
+	l.width = cols * opts.ModuleSize
+	if twoD {
+		l.height = (len(modules) + 2*quiet) * opts.ModuleSize
+	} else {
+		if l.opts.BarHeight <= 0 {
+			l.opts.BarHeight = 80
+		}
+		if opts.ShowText {
+			l.textHeight = 18
+		}
+		l.height = l.opts.BarHeight + l.textHeight
+	}
+	return l, nil
+}
+
+// moduleRect retourne la zone en pixels du module (x, y).
+func (l *codeLayout) moduleRect(x, y int) image.Rectangle {
+	s := l.opts.ModuleSize
+	px := (x + l.quiet) * s
+	if len(l.modules) == 1 {
+		return image.Rect(px, 0, px+s, l.opts.BarHeight)
+	}
+	py := (y + l.quiet) * s
+	return image.Rect(px, py, px+s, py+s)
+}
+
+// logoRect centre la zone du logo sur le code en gardant ses proportions.
+func (l *codeLayout) logoRect(logo image.Image) image.Rectangle {
+	ratio := l.opts.LogoSize
+	if ratio <= 0 {
+		ratio = 0.2
+	}
+	ratio = math.Min(ratio, 0.3)
+	size := int(float64(len(l.modules[0])*l.opts.ModuleSize) * ratio)
+
+	lb := logo.Bounds()
+	w, h := size, size
+	if lb.Dx() > lb.Dy() {
+		h = max(1, size*lb.Dy()/lb.Dx())
+	} else {
+		w = max(1, size*lb.Dx()/lb.Dy())
+	}
+	x, y := (l.width-w)/2, (l.height-h)/2
+	return image.Rect(x, y, x+w, y+h)
+}
+
+func (l *codeLayout) raster(logo image.Image) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, l.width, l.height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(l.bg), image.Point{}, draw.Src)
+	fill := image.NewUniform(l.fg)
+	for y, row := range l.modules {
+		for x, on := range row {
+			if on {
+				draw.Draw(img, l.moduleRect(x, y), fill, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	if logo != nil {
+		r := l.logoRect(logo)
+		// Fond uni autour du logo pour qu'il se détache des modules
+		draw.Draw(img, r.Inset(-l.opts.ModuleSize), image.NewUniform(l.bg), image.Point{}, draw.Src)
+		scaled := resize(logo, r.Dx(), r.Dy())
+		draw.Draw(img, r, scaled, scaled.Bounds().Min, draw.Over)
+	}
+
+	if l.textHeight > 0 {
+		d := &font.Drawer{Dst: img, Src: fill, Face: basicfont.Face7x13}
+		w := d.MeasureString(l.text).Ceil()
+		d.Dot = fixed.P((l.width-w)/2, l.opts.BarHeight+14)
+		d.DrawString(l.text)
+	}
+	return img
+}
+
+func (l *codeLayout) svg(logo image.Image) ([]byte, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		l.width, l.height, l.width, l.height)
+	fmt.Fprintf(&sb, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", svgColor(l.bg))
+
+	// Un seul chemin : les modules consécutifs d'une ligne sont fusionnés
+	fmt.Fprintf(&sb, `<path fill="%s" d="`, svgColor(l.fg))
+	for y, row := range l.modules {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			r := l.moduleRect(start, y)
+			fmt.Fprintf(&sb, "M%d %dh%dv%dh-%dz", r.Min.X, r.Min.Y, (x-start)*l.opts.ModuleSize, r.Dy(), (x-start)*l.opts.ModuleSize)
+		}
+	}
+	sb.WriteString("\"/>\n")
+
+	if logo != nil {
+		r := l.logoRect(logo)
+		bg := r.Inset(-l.opts.ModuleSize)
+		fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", bg.Min.X, bg.Min.Y, bg.Dx(), bg.Dy(), svgColor(l.bg))
+		png, err := encodeImage(fitInBox(logo, r.Dx()*2, r.Dy()*2), nil, "PNG", applyDefaults(&Options{Format: "PNG"}))
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&sb, `<image x="%d" y="%d" width="%d" height="%d" href="data:image/png;base64,%s"/>`+"\n",
+			r.Min.X, r.Min.Y, r.Dx(), r.Dy(), base64.StdEncoding.EncodeToString(png))
+	}
+
+	if l.textHeight > 0 {
+		fmt.Fprintf(&sb, `<text x="%d" y="%d" font-family="monospace" font-size="14" text-anchor="middle" fill="%s">%s</text>`+"\n",
+			l.width/2, l.opts.BarHeight+14, svgColor(l.fg), html.EscapeString(l.text))
+	}
+	sb.WriteString("</svg>\n")
+	return []byte(sb.String()), nil
+}
+
+func svgColor(c color.NRGBA) string {
+	if c.A == 255 {
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("rgba(%d,%d,%d,%.3f)", c.R, c.G, c.B, float64(c.A)/255)
+}
@@ -0,0 +1,259 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ContactSheetOptions définit la mise en page d'une planche contact
+type ContactSheetOptions struct {
+	Columns    int    // nombre de colonnes (4 par défaut)
+	CellWidth  int    // largeur d'une cellule en pixels (240 par défaut)
+	CellHeight int    // hauteur de la vignette en pixels, hors légende (180 par défaut)
+	Spacing    *int   // espace entre les cellules et autour de la planche (nil = 10, 0 = sans espace)
+	Rows       int    // lignes par page, 0 = tout sur une seule page
+	Captions   bool   // affiche le nom du fichier et les dimensions sous chaque vignette
+	Background string // couleur de fond (#RRGGBB, blanc par défaut)
+	TextColor  string // couleur des légendes (#RRGGBB, noir par défaut)
+	Format     string // "png", "jpeg" ou "pdf" (défaut : déduit de l'extension de sortie)
+	Quality    int    // qualité JPEG (aussi utilisée dans le PDF)
+}
+
+// ContactSheetResult liste les fichiers produits
+type ContactSheetResult struct {
+	Files []string `json:"files"`
+	Pages int      `json:"pages"`
+	Count int      `json:"count"`
+}
+
+type sheetCell struct {
+	thumb   image.Image
+	caption []string
+}
+
+const captionLineHeight = 15
+
+// BuildContactSheet dispose les images en grille et écrit la planche dans output.
+// Avec plusieurs pages en PNG/JPEG, les fichiers sont suffixés par leur numéro.
+func BuildContactSheet(paths []string, output string, opts ContactSheetOptions) (*ContactSheetResult, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("aucune image à disposer")
+	}
+	opts = contactSheetDefaults(opts, output)
+	format := strings.ToUpper(opts.Format)
+	if format != "PNG" && format != "JPEG" && format != "JPG" && format != "PDF" {
+		return nil, fmt.Errorf("format de planche '%s' non supporté", opts.Format)
+	}
+	bg, err := parseHexColor(opts.Background, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+	if err != nil {
+		return nil, err
+	}
+	fg, err := parseHexColor(opts.TextColor, color.NRGBA{A: 255})
+	if err != nil {
+		return nil, err
+	}
+	if dir := filepath.Dir(output); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("impossible de créer le dossier de sortie : %w", err)
+		}
+	}
+
+	// Vignettes : chaque image est réduite dès son décodage pour limiter la mémoire
+	cells := make([]sheetCell, len(paths))
+	_, err = ParallelConvert(paths, func(i int, path string) ([]byte, error) {
+		img, err := decodeFile(path)
+		if err != nil {
+			return nil, err
+		}
+		b := img.Bounds()
+		cells[i] = sheetCell{
+			thumb: fitInBox(img, opts.CellWidth, opts.CellHeight),
+			caption: []string{
+				asciiCaption(filepath.Base(path)),
+				fmt.Sprintf("%d x %d", b.Dx(), b.Dy()),
+			},
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	perPage := len(cells)
+	if opts.Rows > 0 {
+		perPage = opts.Rows * opts.Columns
+	}
+
+	var pages []*image.NRGBA
+	for start := 0; start < len(cells); start += perPage {
+		end := min(start+perPage, len(cells))
+		pages = append(pages, renderSheetPage(cells[start:end], opts, bg, fg))
+	}
+
+	result := &ContactSheetResult{Pages: len(pages), Count: len(cells)}
+	encOpts := &Options{Format: format, Quality: opts.Quality}
+	applyDefaults(encOpts)
+
+	if format == "PDF" {
+		var pdfPages []pdfPage
+		for _, page := range pages {
+			data, err := encodeImage(page, nil, "JPEG", encOpts)
+			if err != nil {
+				return nil, err
+			}
+			pi, err := newPDFImage(data)
+			if err != nil {
+				return nil, err
+			}
+			// 1 pixel = 0,75 point (96 dpi)
+			w, h := float64(pi.width)*0.75, float64(pi.height)*0.75
+			pdfPages = append(pdfPages, pdfPage{width: w, height: h, images: []pdfPlacement{{image: pi, w: w, h: h}}})
+		}
+		var buf bytes.Buffer
+		if err := writePDF(&buf, pdfPages); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(output, buf.Bytes(), 0o644); err != nil {
+			return nil, fmt.Errorf("échec écriture '%s' : %w", output, err)
+		}
+		result.Files = []string{output}
+		return result, nil
+	}
+
+	ext := filepath.Ext(output)
+	base := strings.TrimSuffix(output, ext)
+	for i, page := range pages {
+		data, err := encodeImage(page, nil, format, encOpts)
+		if err != nil {
+			return nil, err
+		}
+		path := output
+		if len(pages) > 1 {
+			path = fmt.Sprintf("%s-%d%s", base, i+1, ext)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return nil, fmt.Errorf("échec écriture '%s' : %w", path, err)
+		}
+		result.Files = append(result.Files, path)
+	}
+	return result, nil
+}
+
+func contactSheetDefaults(opts ContactSheetOptions, output string) ContactSheetOptions {
+	if opts.Columns <= 0 {
+		opts.Columns = 4
+	}
+	if opts.CellWidth <= 0 {
+		opts.CellWidth = 240
+	}
+	if opts.CellHeight <= 0 {
+		opts.CellHeight = 180
+	}
+	spacing := 10
+	if opts.Spacing != nil {
+		spacing = max(0, *opts.Spacing)
+	}
+	opts.Spacing = &spacing
+	if opts.Quality <= 0 {
+		opts.Quality = 90
+	}
+	if opts.Format == "" {
+		opts.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(output)), ".")
+		if opts.Format == "" {
+			opts.Format = "png"
+		}
+	}
+	return opts
+}
+
+// fitInBox réduit l'image pour tenir dans w×h en gardant ses proportions (sans agrandissement).
+func fitInBox(img image.Image, w, h int) image.Image {
+	b := img.Bounds()
+	scale := math.Min(float64(w)/float64(b.Dx()), float64(h)/float64(b.Dy()))
+	if scale >= 1 {
+		return img
+	}
+	return resize(img, max(1, int(math.Round(float64(b.Dx())*scale))), max(1, int(math.Round(float64(b.Dy())*scale))))
+}
+
+// renderSheetPage dessine une page de la grille.
+func renderSheetPage(cells []sheetCell, opts ContactSheetOptions, bg, fg color.NRGBA) *image.NRGBA {
+	captionH := 0
+	if opts.Captions {
+		captionH = 2*captionLineHeight + 4
+	}
+	spacing := *opts.Spacing
+	cols := min(opts.Columns, len(cells))
+	rows := (len(cells) + opts.Columns - 1) / opts.Columns
+	cellH := opts.CellHeight + captionH
+
+	width := cols*opts.CellWidth + (cols+1)*spacing
+	height := rows*cellH + (rows+1)*spacing
+	page := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(page, page.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+
+	for i, c := range cells {
+		col, row := i%opts.Columns, i/opts.Columns
+		x := spacing + col*(opts.CellWidth+spacing)
+		y := spacing + row*(cellH+spacing)
+
+		tb := c.thumb.Bounds()
+		tx := x + (opts.CellWidth-tb.Dx())/2
+		ty := y + (opts.CellHeight-tb.Dy())/2
+		draw.Draw(page, image.Rect(tx, ty, tx+tb.Dx(), ty+tb.Dy()), c.thumb, tb.Min, draw.Over)
+
+		if opts.Captions {
+			for l, line := range c.caption {
+				drawCaption(page, line, x, y+opts.CellHeight+(l+1)*captionLineHeight, opts.CellWidth, fg)
+			}
+		}
+	}
+	return page
+}
+
+// asciiCaption ramène un texte à l'ASCII, seul jeu couvert par la police bitmap : les accents
+// sont retirés (é → e) et les autres caractères remplacés par '?'.
+func asciiCaption(text string) string {
+	var sb strings.Builder
+	for _, r := range norm.NFD.String(text) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case r < 0x20 || r > 0x7e:
+			sb.WriteByte('?')
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// drawCaption écrit une ligne centrée dans la largeur donnée, tronquée si besoin.
+func drawCaption(dst draw.Image, text string, x, baseline, width int, fg color.NRGBA) {
+	face := basicfont.Face7x13
+	d := &font.Drawer{Dst: dst, Src: image.NewUniform(fg), Face: face}
+
+	if d.MeasureString(text).Ceil() > width {
+		runes := []rune(text)
+		for len(runes) > 0 && d.MeasureString(string(runes)+"...").Ceil() > width {
+			runes = runes[:len(runes)-1]
+		}
+		text = string(runes) + "..."
+	}
+
+	w := d.MeasureString(text).Ceil()
+	d.Dot = fixed.P(x+(width-w)/2, baseline)
+	d.DrawString(text)
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"image"
+	_ "image/gif" // décodage des GIF (première image) pour les outils de dossier
 	"image/jpeg"
 	"image/png"
 	"io"
+	"os"
 	"strings"
 
 	"github.com/Kagami/go-avif"
@@ -16,8 +18,12 @@ import (
 )
 
 func ConvertFromReader(r io.Reader, opts *Options) ([]byte, error) {
-	// 1. Décodage
-	img, _, err := image.Decode(r)
+	// 1. Lecture et décodage
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("erreur de lecture de l'image : %w", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(src))
 	if err != nil {
 		return nil, fmt.Errorf("erreur de décodage de l'image : %w", err)
 	}
@@ -26,8 +32,50 @@ func ConvertFromReader(r io.Reader, opts *Options) ([]byte, error) {
 	opts = applyDefaults(opts)
 	format := strings.ToUpper(opts.Format)
 
+	// Profil couleur : conversion vers sRGB ou conservation du profil
+	img, profile := applyColorProfile(img, extractICC(src), format, opts)
+
+	if opts.Transparency != TransparencyNone && !supportsAlpha(format) {
+		return nil, fmt.Errorf("le format %s ne gère pas la transparence (utiliser PNG ou WebP)", format)
+	}
+
+	// Opérations géométriques (orientation, rotation, recadrage…)
+	img, err = applyTransforms(img, exifOrientation(src), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeImage(img, profile, format, opts)
+}
+
+// decodeFile lit et décode une image en appliquant son orientation EXIF.
+func decodeFile(path string) (image.Image, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("échec lecture '%s' : %w", path, err)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("erreur de décodage de '%s' : %w", path, err)
+	}
+	return applyOrientation(img, exifOrientation(src)), nil
+}
+
+// encodableFormats liste les formats acceptés par encodeImage (en majuscules)
+var encodableFormats = map[string]bool{
+	"PNG": true, "JPEG": true, "JPG": true, "WEBP": true, "AVIF": true, "BMP": true, "TIFF": true,
+}
+
+// encodeImage encode une image décodée dans le format demandé (format en majuscules)
+// et y intègre le profil ICC éventuel.
+func encodeImage(img image.Image, profile []byte, format string, opts *Options) ([]byte, error) {
+	// Profondeur : 16 bits conservés si le format les supporte, sinon réduction
+	img = adjustDepth(img, format, opts)
+
 	// 3. Buffer de sortie
 	var buf bytes.Buffer
+	var err error
 
 	// 4. Encodage selon le format + options
 	switch format {
@@ -37,7 +85,11 @@ func ConvertFromReader(r io.Reader, opts *Options) ([]byte, error) {
 
 	case "JPEG", "JPG":
 		// Qualité de 1 à 100 (85 par défaut)
-		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: opts.Quality})
+		if usesCustomJPEG(opts) {
+			err = encodeJPEG(&buf, img, opts)
+		} else {
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: opts.Quality})
+		}
 
 	case "WEBP":
 		err = webp.Encode(&buf, img, &webp.Options{
@@ -68,5 +120,8 @@ func ConvertFromReader(r io.Reader, opts *Options) ([]byte, error) {
 		return nil, fmt.Errorf("échec de l'encodage %s : %w", format, err)
 	}
 
+	if profile != nil && iccMatchesImage(profile, img, format) {
+		return embedICC(buf.Bytes(), format, profile)
+	}
 	return buf.Bytes(), nil
 }
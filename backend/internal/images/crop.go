@@ -0,0 +1,167 @@
+package images
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Modes de recadrage (Options.Crop)
+const (
+	CropNone   = ""
+	CropRect   = "rect"   // rectangle explicite CropX/CropY/CropWidth/CropHeight
+	CropAspect = "aspect" // recadrage centré au ratio AspectRatio
+	CropSmart  = "smart"  // recadrage au ratio AspectRatio sur la zone la plus détaillée
+)
+
+// applyCrop recadre l'image selon les options.
+func applyCrop(img image.Image, opts *Options) (image.Image, error) {
+	b := img.Bounds()
+	var r image.Rectangle
+
+	switch opts.Crop {
+	case CropNone:
+		return img, nil
+
+	case CropRect:
+		r = image.Rect(opts.CropX, opts.CropY, opts.CropX+opts.CropWidth, opts.CropY+opts.CropHeight).
+			Add(b.Min).Intersect(b)
+		if r.Empty() {
+			return nil, fmt.Errorf("rectangle de recadrage hors de l'image")
+		}
+
+	case CropAspect, CropSmart:
+		rw, rh, err := parseAspectRatio(opts.AspectRatio)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Crop == CropAspect {
+			r = centeredAspectRect(b, rw, rh)
+		} else {
+			r = smartCropRect(img, rw, rh)
+		}
+
+	default:
+		return nil, fmt.Errorf("mode de recadrage '%s' inconnu", opts.Crop)
+	}
+
+	return cropTo(img, r), nil
+}
+
+// parseAspectRatio lit un ratio de la forme "16:9" (ou "16/9", "1.5").
+func parseAspectRatio(s string) (float64, float64, error) {
+	s = strings.TrimSpace(s)
+	sep := strings.IndexAny(s, ":/x")
+	if sep < 0 {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v <= 0 {
+			return 0, 0, fmt.Errorf("ratio '%s' invalide", s)
+		}
+		return v, 1, nil
+	}
+	w, err1 := strconv.ParseFloat(strings.TrimSpace(s[:sep]), 64)
+	h, err2 := strconv.ParseFloat(strings.TrimSpace(s[sep+1:]), 64)
+	if err1 != nil || err2 != nil || w <= 0 || h <= 0 {
+		return 0, 0, fmt.Errorf("ratio '%s' invalide", s)
+	}
+	return w, h, nil
+}
+
+// aspectSize renvoie la plus grande taille au ratio rw:rh contenue dans w×h.
+func aspectSize(w, h int, rw, rh float64) (int, int) {
+	cw, ch := w, int(math.Round(float64(w)*rh/rw))
+	if ch > h {
+		cw, ch = int(math.Round(float64(h)*rw/rh)), h
+	}
+	return max(1, cw), max(1, ch)
+}
+
+// centeredAspectRect calcule le plus grand rectangle centré au ratio demandé.
+func centeredAspectRect(b image.Rectangle, rw, rh float64) image.Rectangle {
+	cw, ch := aspectSize(b.Dx(), b.Dy(), rw, rh)
+	x := b.Min.X + (b.Dx()-cw)/2
+	y := b.Min.Y + (b.Dy()-ch)/2
+	return image.Rect(x, y, x+cw, y+ch)
+}
+
+// smartCropRect choisit, parmi les rectangles au ratio demandé, celui qui concentre le plus
+// de détails. La saillance est estimée par l'énergie des gradients de luminance et la
+// saturation, calculées sur une version réduite de l'image, avec un léger biais vers le centre.
+func smartCropRect(img image.Image, rw, rh float64) image.Rectangle {
+	b := img.Bounds()
+	cw, ch := aspectSize(b.Dx(), b.Dy(), rw, rh)
+	if cw == b.Dx() && ch == b.Dy() {
+		return b
+	}
+
+	small := fitWithin(img, 256)
+	sw, sh := small.Rect.Dx(), small.Rect.Dy()
+	scale := float64(sw) / float64(b.Dx())
+
+	lum := make([]float64, sw*sh)
+	sat := make([]float64, sw*sh)
+	for i := range lum {
+		o := i * 4
+		r, g, bl := float64(small.Pix[o]), float64(small.Pix[o+1]), float64(small.Pix[o+2])
+		lum[i] = 0.299*r + 0.587*g + 0.114*bl
+		sat[i] = math.Max(r, math.Max(g, bl)) - math.Min(r, math.Min(g, bl))
+	}
+
+	// Carte d'énergie puis image intégrale pour évaluer chaque fenêtre en O(1)
+	integral := make([]float64, (sw+1)*(sh+1))
+	for y := 0; y < sh; y++ {
+		for x := 0; x < sw; x++ {
+			var e float64
+			if x > 0 && x < sw-1 && y > 0 && y < sh-1 {
+				gx := lum[y*sw+x+1] - lum[y*sw+x-1]
+				gy := lum[(y+1)*sw+x] - lum[(y-1)*sw+x]
+				e = math.Sqrt(gx*gx+gy*gy) + 0.25*sat[y*sw+x]
+			}
+			dx := (float64(x)/float64(sw) - 0.5) * 2
+			dy := (float64(y)/float64(sh) - 0.5) * 2
+			e *= 1 - 0.2*(dx*dx+dy*dy)/2
+			integral[(y+1)*(sw+1)+x+1] = e + integral[y*(sw+1)+x+1] + integral[(y+1)*(sw+1)+x] - integral[y*(sw+1)+x]
+		}
+	}
+	sum := func(x0, y0, x1, y1 int) float64 {
+		return integral[y1*(sw+1)+x1] - integral[y0*(sw+1)+x1] - integral[y1*(sw+1)+x0] + integral[y0*(sw+1)+x0]
+	}
+
+	ww := min(sw, max(1, int(math.Round(float64(cw)*scale))))
+	wh := min(sh, max(1, int(math.Round(float64(ch)*scale))))
+	bestX, bestY := (sw-ww)/2, (sh-wh)/2
+	best := sum(bestX, bestY, bestX+ww, bestY+wh)
+	for y := 0; y+wh <= sh; y++ {
+		for x := 0; x+ww <= sw; x++ {
+			if s := sum(x, y, x+ww, y+wh); s > best {
+				best, bestX, bestY = s, x, y
+			}
+		}
+	}
+
+	// Retour aux coordonnées de l'image source
+	x := b.Min.X + min(b.Dx()-cw, int(math.Round(float64(bestX)/scale)))
+	y := b.Min.Y + min(b.Dy()-ch, int(math.Round(float64(bestY)/scale)))
+	return image.Rect(x, y, x+cw, y+ch)
+}
+
+// cropTo extrait le rectangle r de l'image (sans copie lorsque c'est possible).
+func cropTo(img image.Image, r image.Rectangle) image.Image {
+	if r == img.Bounds() {
+		return img
+	}
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(r)
+	}
+	dst := newCanvasLike(img, image.Rect(0, 0, r.Dx(), r.Dy()))
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			dst.Set(x-r.Min.X, y-r.Min.Y, img.At(x, y))
+		}
+	}
+	return dst
+}
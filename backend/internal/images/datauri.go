@@ -0,0 +1,181 @@
+package images
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DataURIOptions définit l'export d'images intégrées (data URI) en CSS ou JSON
+type DataURIOptions struct {
+	Encoding    *Options // conversion avant intégration (nil = fichiers intégrés tels quels)
+	MaxBytes    int      // taille maximale d'une image intégrée, au-delà elle est ignorée (0 = pas de limite)
+	CSSFile     string   // si renseigné, écrit une classe background-image par image
+	JSONFile    string   // si renseigné, écrit la table nom → data URI
+	ClassPrefix string   // préfixe des classes CSS ("img-" par défaut)
+}
+
+// DataURIEntry est une image intégrée
+type DataURIEntry struct {
+	Source  string `json:"source"`
+	Name    string `json:"name"` // clé JSON et suffixe de la classe CSS
+	Mime    string `json:"mime"`
+	Size    int    `json:"size"` // octets de l'image encodée (avant base64)
+	Width   int    `json:"width,omitempty"`
+	Height  int    `json:"height,omitempty"`
+	DataURI string `json:"data_uri"`
+}
+
+// DataURISkipped est une image non intégrée et la raison
+type DataURISkipped struct {
+	Source string `json:"source"`
+	Size   int    `json:"size"`
+	Reason string `json:"reason"`
+}
+
+// DataURIExport est le résultat de l'export
+type DataURIExport struct {
+	Entries  []DataURIEntry   `json:"entries"`
+	Skipped  []DataURISkipped `json:"skipped"`
+	CSSFile  string           `json:"css_file,omitempty"`
+	JSONFile string           `json:"json_file,omitempty"`
+}
+
+// ExportDataURIs convertit les images puis les exporte en data URI, dans une feuille CSS
+// et/ou une table JSON. Les images plus lourdes que MaxBytes après conversion sont ignorées.
+func ExportDataURIs(paths []string, opts DataURIOptions) (*DataURIExport, error) {
+	if opts.ClassPrefix == "" {
+		opts.ClassPrefix = "img-"
+	}
+
+	entries := make([]*DataURIEntry, len(paths))
+	skipped := make([]*DataURISkipped, len(paths))
+	_, err := ParallelConvert(paths, func(i int, path string) ([]byte, error) {
+		data, format, err := dataURIBytes(path, opts.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		if opts.MaxBytes > 0 && len(data) > opts.MaxBytes {
+			skipped[i] = &DataURISkipped{Source: path, Size: len(data), Reason: fmt.Sprintf("plus de %d octets", opts.MaxBytes)}
+			return nil, nil
+		}
+		mime, ok := mimeTypes[format]
+		if !ok {
+			skipped[i] = &DataURISkipped{Source: path, Size: len(data), Reason: fmt.Sprintf("format %s non affichable par un navigateur", format)}
+			return nil, nil
+		}
+
+		e := &DataURIEntry{
+			Source:  path,
+			Mime:    mime,
+			Size:    len(data),
+			DataURI: "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data),
+		}
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+			e.Width, e.Height = cfg.Width, cfg.Height
+		}
+		entries[i] = e
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &DataURIExport{Entries: []DataURIEntry{}, Skipped: []DataURISkipped{}}
+	used := map[string]int{}
+	for i := range paths {
+		if s := skipped[i]; s != nil {
+			res.Skipped = append(res.Skipped, *s)
+			continue
+		}
+		e := entries[i]
+		base := cssClassInvalid.ReplaceAllString(strings.TrimSuffix(filepath.Base(e.Source), filepath.Ext(e.Source)), "-")
+		used[base]++
+		e.Name = base
+		if n := used[base]; n > 1 {
+			e.Name = fmt.Sprintf("%s-%d", base, n)
+		}
+		res.Entries = append(res.Entries, *e)
+	}
+
+	if opts.CSSFile != "" {
+		if err := writeOutputFile(opts.CSSFile, []byte(dataURICSS(res.Entries, opts.ClassPrefix))); err != nil {
+			return nil, err
+		}
+		res.CSSFile = opts.CSSFile
+	}
+	if opts.JSONFile != "" {
+		table := make(map[string]string, len(res.Entries))
+		for _, e := range res.Entries {
+			table[e.Name] = e.DataURI
+		}
+		data, err := json.MarshalIndent(table, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := writeOutputFile(opts.JSONFile, data); err != nil {
+			return nil, err
+		}
+		res.JSONFile = opts.JSONFile
+	}
+	return res, nil
+}
+
+// dataURIBytes retourne les octets à intégrer et leur format (en majuscules).
+func dataURIBytes(path string, encoding *Options) ([]byte, string, error) {
+	if encoding == nil {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("échec lecture '%s' : %w", path, err)
+		}
+		return data, strings.ToUpper(strings.TrimPrefix(filepath.Ext(path), ".")), nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("échec ouverture '%s' : %w", path, err)
+	}
+	defer file.Close()
+
+	opts := *encoding
+	data, err := ConvertFromReader(file, &opts)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, strings.ToUpper(opts.Format), nil
+}
+
+// dataURICSS génère une classe par image, triées par nom.
+func dataURICSS(entries []DataURIEntry, prefix string) string {
+	sorted := append([]DataURIEntry(nil), entries...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var sb strings.Builder
+	for _, e := range sorted {
+		fmt.Fprintf(&sb, ".%s%s {\n", prefix, e.Name)
+		fmt.Fprintf(&sb, "  background-image: url(\"%s\");\n", e.DataURI)
+		if e.Width > 0 && e.Height > 0 {
+			fmt.Fprintf(&sb, "  width: %dpx;\n  height: %dpx;\n", e.Width, e.Height)
+		}
+		sb.WriteString("}\n\n")
+	}
+	return sb.String()
+}
+
+func writeOutputFile(path string, data []byte) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("impossible de créer le dossier de sortie : %w", err)
+		}
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("échec écriture '%s' : %w", path, err)
+	}
+	return nil
+}
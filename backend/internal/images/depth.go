@@ -0,0 +1,144 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// isHighDepth indique si l'image porte plus de 8 bits par canal.
+func isHighDepth(img image.Image) bool {
+	switch img.ColorModel() {
+	case color.RGBA64Model, color.NRGBA64Model, color.Gray16Model:
+		return true
+	}
+	return false
+}
+
+// supportsHighDepth indique si l'encodeur du format sait écrire 16 bits par canal.
+func supportsHighDepth(format string) bool {
+	return format == "PNG" || format == "TIFF"
+}
+
+// newCanvasLike crée une image modifiable de même profondeur que src (16 ou 8 bits).
+func newCanvasLike(src image.Image, r image.Rectangle) draw.Image {
+	if isHighDepth(src) {
+		return image.NewNRGBA64(r)
+	}
+	return image.NewNRGBA(r)
+}
+
+// adjustDepth prépare l'image pour l'encodeur : conservation des 16 bits lorsque le
+// format le permet, sinon réduction explicite à 8 bits (avec tramage optionnel).
+func adjustDepth(img image.Image, format string, opts *Options) image.Image {
+	if !isHighDepth(img) {
+		return img
+	}
+
+	if opts.BitDepth != 8 && supportsHighDepth(format) {
+		// Les encodeurs PNG/TIFF ne gardent 16 bits que pour ces types concrets
+		switch img.(type) {
+		case *image.RGBA64, *image.NRGBA64, *image.Gray16:
+			return img
+		}
+		b := img.Bounds()
+		if img.ColorModel() == color.Gray16Model {
+			dst := image.NewGray16(b)
+			draw.Draw(dst, b, img, b.Min, draw.Src)
+			return dst
+		}
+		dst := image.NewNRGBA64(b)
+		draw.Draw(dst, b, img, b.Min, draw.Src)
+		return dst
+	}
+
+	if opts.Dither {
+		return ditherTo8(img)
+	}
+	b := img.Bounds()
+	if img.ColorModel() == color.Gray16Model {
+		dst := image.NewGray(b)
+		draw.Draw(dst, b, img, b.Min, draw.Src)
+		return dst
+	}
+	dst := image.NewNRGBA(b)
+	draw.Draw(dst, b, img, b.Min, draw.Src)
+	return dst
+}
+
+// ditherTo8 réduit une image 16 bits à 8 bits par diffusion d'erreur Floyd-Steinberg.
+// Le canal alpha est simplement tronqué.
+func ditherTo8(img image.Image) image.Image {
+	b := img.Bounds()
+	w := b.Dx()
+	gray := img.ColorModel() == color.Gray16Model
+
+	channels := 3
+	if gray {
+		channels = 1
+	}
+
+	// Erreurs de la ligne courante et de la suivante (avec une marge de chaque côté)
+	cur := make([]float64, (w+2)*channels)
+	next := make([]float64, (w+2)*channels)
+
+	var grayDst *image.Gray
+	var rgbDst *image.NRGBA
+	if gray {
+		grayDst = image.NewGray(b)
+	} else {
+		rgbDst = image.NewNRGBA(b)
+	}
+
+	quantize := func(v float64) (uint8, float64) {
+		q := math.Round(v / 257)
+		if q < 0 {
+			q = 0
+		} else if q > 255 {
+			q = 255
+		}
+		return uint8(q), v - q*257
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := 0; x < w; x++ {
+			var vals [3]float64
+			var alpha uint16
+			if gray {
+				vals[0] = float64(color.Gray16Model.Convert(img.At(b.Min.X+x, y)).(color.Gray16).Y)
+			} else {
+				c := color.NRGBA64Model.Convert(img.At(b.Min.X+x, y)).(color.NRGBA64)
+				vals = [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+				alpha = c.A
+			}
+
+			var out [3]uint8
+			for ch := 0; ch < channels; ch++ {
+				i := (x+1)*channels + ch
+				q, e := quantize(vals[ch] + cur[i])
+				out[ch] = q
+				cur[i+channels] += e * 7 / 16
+				next[i-channels] += e * 3 / 16
+				next[i] += e * 5 / 16
+				next[i+channels] += e * 1 / 16
+			}
+
+			if gray {
+				grayDst.Pix[(y-b.Min.Y)*grayDst.Stride+x] = out[0]
+			} else {
+				o := (y-b.Min.Y)*rgbDst.Stride + x*4
+				rgbDst.Pix[o], rgbDst.Pix[o+1], rgbDst.Pix[o+2], rgbDst.Pix[o+3] = out[0], out[1], out[2], uint8(alpha>>8)
+			}
+		}
+		cur, next = next, cur
+		for i := range next {
+			next[i] = 0
+		}
+	}
+
+	if gray {
+		return grayDst
+	}
+	return rgbDst
+}
@@ -0,0 +1,130 @@
+package images
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// DiffOptions définit la comparaison de deux images
+type DiffOptions struct {
+	Tolerance int    // écart maximal par canal (0–255) toléré avant de compter un pixel comme différent
+	Resize    bool   // redimensionne la seconde image à la taille de la première si elles diffèrent
+	Output    string // si renseigné, écrit l'image des différences (PNG) à ce chemin
+	Highlight string // couleur de surlignage des différences (#RRGGBB, rouge par défaut)
+}
+
+// DiffResult résume la comparaison
+type DiffResult struct {
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	DiffPixels  int     `json:"diff_pixels"`
+	DiffPercent float64 `json:"diff_percent"`
+	MaxDelta    int     `json:"max_delta"` // plus grand écart par canal rencontré
+	PSNR        float64 `json:"psnr"`      // en dB, 0 si les images sont identiques
+	Identical   bool    `json:"identical"`
+	Resized     bool    `json:"resized"`
+	OutputFile  string  `json:"output_file,omitempty"`
+}
+
+// CompareFiles compare deux images pixel à pixel (orientation EXIF appliquée).
+func CompareFiles(pathA, pathB string, opts DiffOptions) (*DiffResult, error) {
+	a, err := decodeFile(pathA)
+	if err != nil {
+		return nil, err
+	}
+	b, err := decodeFile(pathB)
+	if err != nil {
+		return nil, err
+	}
+
+	res, diff, err := CompareImages(a, b, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Output == "" {
+		return res, nil
+	}
+
+	if dir := filepath.Dir(opts.Output); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("impossible de créer le dossier de sortie : %w", err)
+		}
+	}
+	data, err := encodeImage(diff, nil, "PNG", applyDefaults(&Options{Format: "PNG"}))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(opts.Output, data, 0o644); err != nil {
+		return nil, fmt.Errorf("échec écriture '%s' : %w", opts.Output, err)
+	}
+	res.OutputFile = opts.Output
+	return res, nil
+}
+
+// CompareImages compare a et b et retourne aussi l'image des différences : l'image a
+// estompée en gris, avec les pixels différents surlignés (intensité selon l'écart).
+func CompareImages(a, b image.Image, opts DiffOptions) (*DiffResult, *image.NRGBA, error) {
+	ab, bb := a.Bounds(), b.Bounds()
+	res := &DiffResult{Width: ab.Dx(), Height: ab.Dy()}
+	if ab.Size() != bb.Size() {
+		if !opts.Resize {
+			return nil, nil, fmt.Errorf("tailles différentes : %d×%d et %d×%d", ab.Dx(), ab.Dy(), bb.Dx(), bb.Dy())
+		}
+		b = resize(b, ab.Dx(), ab.Dy())
+		bb = b.Bounds()
+		res.Resized = true
+	}
+	highlight, err := parseHexColor(opts.Highlight, color.NRGBA{R: 255, A: 255})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w, h := ab.Dx(), ab.Dy()
+	pa := image.NewNRGBA(image.Rect(0, 0, w, h))
+	pb := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(pa, pa.Bounds(), a, ab.Min, draw.Src)
+	draw.Draw(pb, pb.Bounds(), b, bb.Min, draw.Src)
+
+	out := image.NewNRGBA(pa.Rect)
+	var sqErr float64
+	for i := 0; i < len(pa.Pix); i += 4 {
+		delta := 0
+		for c := 0; c < 4 && (pa.Pix[i+3] != 0 || pb.Pix[i+3] != 0); c++ { // deux pixels transparents sont identiques
+			d := absDiff(pa.Pix[i+c], pb.Pix[i+c])
+			delta = max(delta, d)
+			if c < 3 {
+				sqErr += float64(d * d)
+			}
+		}
+		res.MaxDelta = max(res.MaxDelta, delta)
+
+		// Fond : luminance de a, éclaircie pour faire ressortir les différences
+		lum := (299*int(pa.Pix[i]) + 587*int(pa.Pix[i+1]) + 114*int(pa.Pix[i+2])) / 1000
+		gray := uint8(255 - (255-lum)/4)
+		out.Pix[i], out.Pix[i+1], out.Pix[i+2], out.Pix[i+3] = gray, gray, gray, 255
+
+		if delta > opts.Tolerance {
+			res.DiffPixels++
+			// Surlignage au moins à moitié opaque, plein pour les plus gros écarts
+			f := 0.5 + 0.5*float64(delta)/255
+			blend := func(bg, fg uint8) uint8 { return uint8(float64(bg)*(1-f) + float64(fg)*f + 0.5) }
+			out.Pix[i] = blend(gray, highlight.R)
+			out.Pix[i+1] = blend(gray, highlight.G)
+			out.Pix[i+2] = blend(gray, highlight.B)
+		}
+	}
+
+	if total := w * h; total > 0 {
+		res.DiffPercent = math.Round(float64(res.DiffPixels)*10000/float64(total)) / 100
+		if mse := sqErr / float64(3*total); mse > 0 {
+			res.PSNR = math.Round(10*math.Log10(255*255/mse)*100) / 100
+		}
+	}
+	res.Identical = res.MaxDelta == 0
+	return res, out, nil
+}
@@ -0,0 +1,171 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// EstimateRange est une valeur estimée et son intervalle de confiance à 95 %
+type EstimateRange struct {
+	Value float64 `json:"value"`
+	Low   float64 `json:"low"`
+	High  float64 `json:"high"`
+}
+
+// ConversionEstimate extrapole à tout le lot les résultats d'un échantillon converti en mémoire
+type ConversionEstimate struct {
+	Files        int           `json:"files"`
+	Sampled      int           `json:"sampled"`
+	Failed       int           `json:"failed"` // échecs de conversion dans l'échantillon
+	InputBytes   int64         `json:"input_bytes"`
+	OutputBytes  EstimateRange `json:"output_bytes"`
+	SavedBytes   EstimateRange `json:"saved_bytes"`
+	SavedPercent EstimateRange `json:"saved_percent"`
+	Seconds      EstimateRange `json:"seconds"` // durée totale, workers parallèles compris
+}
+
+const defaultEstimateSample = 30
+
+// EstimateConversion convertit un échantillon des fichiers avec les options données, sans rien
+// écrire, et extrapole la taille de sortie, le gain et la durée du lot complet.
+// L'échantillon est stratifié par taille de fichier pour représenter petits et gros fichiers.
+func EstimateConversion(paths []string, opts *Options, sampleSize int) (*ConversionEstimate, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("aucun fichier à estimer")
+	}
+	if sampleSize <= 0 {
+		sampleSize = defaultEstimateSample
+	}
+
+	type file struct {
+		path string
+		size int64
+	}
+	files := make([]file, 0, len(paths))
+	est := &ConversionEstimate{Files: len(paths)}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("fichier inaccessible '%s' : %w", p, err)
+		}
+		files = append(files, file{p, info.Size()})
+		est.InputBytes += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].size < files[j].size })
+
+	// Un fichier au milieu de chaque tranche de taille
+	n := min(sampleSize, len(files))
+	sample := make([]file, n)
+	for i := range sample {
+		sample[i] = files[(2*i+1)*len(files)/(2*n)]
+	}
+
+	type measure struct {
+		in, out int64
+		seconds float64
+		ok      bool
+	}
+	measures := make([]measure, n)
+	conversionCost := ConversionCost(opts)
+	fileCost := func(f file) int64 { return conversionCost(f.path) }
+	_, err := ParallelConvertWithCost(sample, fileCost, func(i int, f file) ([]byte, error) {
+		src, err := os.ReadFile(f.path)
+		if err != nil {
+			return nil, nil
+		}
+		o := Options{}
+		if opts != nil {
+			o = *opts
+		}
+		start := time.Now()
+		data, err := ConvertFromReader(bytes.NewReader(src), &o)
+		if err != nil {
+			return nil, nil // compté comme échec, l'estimation continue
+		}
+		measures[i] = measure{in: f.size, out: int64(len(data)), seconds: time.Since(start).Seconds(), ok: true}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ins, outs, secs []float64
+	for _, m := range measures {
+		if !m.ok {
+			est.Failed++
+			continue
+		}
+		ins = append(ins, float64(m.in))
+		outs = append(outs, float64(m.out))
+		secs = append(secs, m.seconds)
+	}
+	est.Sampled = len(ins)
+	if est.Sampled == 0 {
+		return nil, fmt.Errorf("aucun fichier de l'échantillon n'a pu être converti")
+	}
+
+	total := float64(est.InputBytes)
+	est.OutputBytes = ratioEstimate(ins, outs, total, len(files)).round(0)
+
+	// Gain = entrée - sortie : l'intervalle s'inverse
+	est.SavedBytes = EstimateRange{
+		Value: total - est.OutputBytes.Value,
+		Low:   total - est.OutputBytes.High,
+		High:  total - est.OutputBytes.Low,
+	}
+	if total > 0 {
+		pct := func(v float64) float64 { return math.Round(v/total*10000) / 100 }
+		est.SavedPercent = EstimateRange{Value: pct(est.SavedBytes.Value), Low: pct(est.SavedBytes.Low), High: pct(est.SavedBytes.High)}
+	}
+
+	// Durée : temps de conversion proportionnel à la taille d'entrée, réparti sur les workers
+	workers := float64(GetParallelLimits().Workers)
+	if workers <= 0 {
+		workers = float64(min(runtime.NumCPU(), 8))
+	}
+	t := ratioEstimate(ins, secs, total, len(files))
+	est.Seconds = EstimateRange{Value: t.Value / workers, Low: t.Low / workers, High: t.High / workers}.round(1)
+	return est, nil
+}
+
+// ratioEstimate extrapole la somme de y sur la population à partir du rapport y/x de
+// l'échantillon (estimateur par le ratio), avec un intervalle à 95 %.
+func ratioEstimate(x, y []float64, totalX float64, population int) EstimateRange {
+	var sx, sy float64
+	for i := range x {
+		sx += x[i]
+		sy += y[i]
+	}
+	if sx == 0 {
+		return EstimateRange{}
+	}
+	r := sy / sx
+	value := r * totalX
+
+	n := float64(len(x))
+	if len(x) < 2 {
+		return EstimateRange{Value: value, Low: value, High: value}
+	}
+	var ss float64
+	for i := range x {
+		d := y[i] - r*x[i]
+		ss += d * d
+	}
+	variance := ss / (n - 1)
+	fpc := math.Max(0, 1-n/float64(population)) // correction de population finie
+	margin := 1.96 * float64(population) * math.Sqrt(fpc*variance/n)
+
+	return EstimateRange{Value: value, Low: math.Max(0, value-margin), High: value + margin}
+}
+
+// round arrondit les bornes au nombre de décimales donné.
+func (e EstimateRange) round(decimals int) EstimateRange {
+	p := math.Pow10(decimals)
+	r := func(v float64) float64 { return math.Round(v*p) / p }
+	return EstimateRange{Value: r(e.Value), Low: r(e.Low), High: r(e.High)}
+}
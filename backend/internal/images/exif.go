@@ -0,0 +1,108 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// exifOrientation retourne l'orientation EXIF (1 à 8) du fichier, 1 si absente.
+func exifOrientation(data []byte) int {
+	var tiffData []byte
+	switch {
+	case len(data) > 4 && data[0] == 0xFF && data[1] == 0xD8:
+		tiffData = exifFromJPEG(data)
+	case len(data) > 8 && bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		tiffData = exifFromPNG(data)
+	case len(data) > 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		tiffData = exifFromWebP(data)
+	case len(data) > 8 && (string(data[0:4]) == "II*\x00" || string(data[0:4]) == "MM\x00*"):
+		tiffData = data
+	}
+
+	o := orientationFromTIFF(tiffData)
+	if o < 1 || o > 8 {
+		return 1
+	}
+	return o
+}
+
+func exifFromJPEG(data []byte) []byte {
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			break
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		n := int(binary.BigEndian.Uint16(data[i+2:]))
+		if n < 2 || i+2+n > len(data) {
+			break
+		}
+		seg := data[i+4 : i+2+n]
+		if marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return seg[6:]
+		}
+		i += 2 + n
+	}
+	return nil
+}
+
+func exifFromPNG(data []byte) []byte {
+	for i := 8; i+8 <= len(data); {
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		if i+12+n > len(data) {
+			break
+		}
+		if string(data[i+4:i+8]) == "eXIf" {
+			return data[i+8 : i+8+n]
+		}
+		i += 12 + n
+	}
+	return nil
+}
+
+func exifFromWebP(data []byte) []byte {
+	for i := 12; i+8 <= len(data); {
+		n := int(binary.LittleEndian.Uint32(data[i+4:]))
+		if i+8+n > len(data) {
+			break
+		}
+		if string(data[i:i+4]) == "EXIF" {
+			return bytes.TrimPrefix(data[i+8:i+8+n], []byte("Exif\x00\x00"))
+		}
+		i += 8 + n + n&1
+	}
+	return nil
+}
+
+// orientationFromTIFF lit le tag 0x0112 de l'IFD0 d'une structure TIFF/EXIF.
+func orientationFromTIFF(d []byte) int {
+	if len(d) < 8 {
+		return 0
+	}
+	var bo binary.ByteOrder
+	switch string(d[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return 0
+	}
+	ifd := int(bo.Uint32(d[4:]))
+	if ifd < 8 || ifd+2 > len(d) {
+		return 0
+	}
+	count := int(bo.Uint16(d[ifd:]))
+	for e := 0; e < count; e++ {
+		p := ifd + 2 + e*12
+		if p+12 > len(d) {
+			return 0
+		}
+		if bo.Uint16(d[p:]) == 0x0112 {
+			return int(bo.Uint16(d[p+8:]))
+		}
+	}
+	return 0
+}
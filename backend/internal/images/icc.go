@@ -0,0 +1,665 @@
+package images
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+)
+
+// Gestion des profils ICC : extraction depuis le fichier source, conversion des
+// pixels vers sRGB (profils matrice/TRC) et ré-intégration du profil en sortie.
+
+// Modes de gestion du profil couleur (Options.ColorProfile)
+const (
+	ColorProfileSRGB   = "srgb"   // conversion des pixels vers sRGB (défaut)
+	ColorProfileKeep   = "keep"   // pixels inchangés, profil ré-intégré dans le fichier
+	ColorProfileIgnore = "ignore" // profil ignoré (ancien comportement)
+)
+
+// iccProfile contient les éléments utiles d'un profil matrice/TRC.
+type iccProfile struct {
+	gray   bool
+	matrix [3][3]float64 // colonnes rXYZ, gXYZ, bXYZ (PCS D50)
+	trc    [3]toneCurve  // une seule courbe pour les profils gris
+}
+
+// toneCurve évalue une courbe de transfert (tag curv ou para) sur [0,1].
+type toneCurve struct {
+	table  []float64 // curv échantillonnée
+	gamma  float64   // curv à une seule valeur
+	kind   int       // para : type de fonction, -1 sinon
+	params [7]float64
+}
+
+// Colorants sRGB adaptés D50 (profil sRGB IEC61966-2.1)
+var srgbD50 = [3][3]float64{
+	{0.4360747, 0.3850649, 0.1430804},
+	{0.2225045, 0.7168786, 0.0606169},
+	{0.0139322, 0.0971045, 0.7141733},
+}
+
+var xyzToSRGB = invert3(srgbD50)
+
+// extractICC retourne le profil ICC embarqué dans un fichier JPEG, PNG, WebP ou TIFF.
+func extractICC(data []byte) []byte {
+	switch {
+	case len(data) > 4 && data[0] == 0xFF && data[1] == 0xD8:
+		return extractICCFromJPEG(data)
+	case len(data) > 8 && bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return extractICCFromPNG(data)
+	case len(data) > 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return extractICCFromWebP(data)
+	case len(data) > 8 && (string(data[0:4]) == "II*\x00" || string(data[0:4]) == "MM\x00*"):
+		return extractICCFromTIFF(data)
+	}
+	return nil
+}
+
+func extractICCFromJPEG(data []byte) []byte {
+	chunks := map[int][]byte{}
+	total := 0
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			break
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		n := int(binary.BigEndian.Uint16(data[i+2:]))
+		if n < 2 || i+2+n > len(data) {
+			break
+		}
+		seg := data[i+4 : i+2+n]
+		if marker == 0xE2 && len(seg) > 14 && string(seg[:12]) == "ICC_PROFILE\x00" {
+			chunks[int(seg[12])] = seg[14:]
+			total = int(seg[13])
+		}
+		i += 2 + n
+	}
+	if len(chunks) == 0 || len(chunks) != total {
+		return nil
+	}
+	var out []byte
+	for seq := 1; seq <= total; seq++ {
+		c, ok := chunks[seq]
+		if !ok {
+			return nil
+		}
+		out = append(out, c...)
+	}
+	return out
+}
+
+func extractICCFromPNG(data []byte) []byte {
+	for i := 8; i+8 <= len(data); {
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		typ := string(data[i+4 : i+8])
+		if n < 0 || i+12+n > len(data) {
+			break
+		}
+		if typ == "iCCP" {
+			chunk := data[i+8 : i+8+n]
+			sep := bytes.IndexByte(chunk, 0)
+			if sep < 0 || sep+2 > len(chunk) {
+				return nil
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(chunk[sep+2:]))
+			if err != nil {
+				return nil
+			}
+			defer zr.Close()
+			profile, err := io.ReadAll(zr)
+			if err != nil {
+				return nil
+			}
+			return profile
+		}
+		if typ == "IDAT" {
+			break
+		}
+		i += 12 + n
+	}
+	return nil
+}
+
+func extractICCFromWebP(data []byte) []byte {
+	for i := 12; i+8 <= len(data); {
+		n := int(binary.LittleEndian.Uint32(data[i+4:]))
+		if i+8+n > len(data) {
+			break
+		}
+		if string(data[i:i+4]) == "ICCP" {
+			return data[i+8 : i+8+n]
+		}
+		i += 8 + n + n&1
+	}
+	return nil
+}
+
+func extractICCFromTIFF(data []byte) []byte {
+	var bo binary.ByteOrder = binary.LittleEndian
+	if data[0] == 'M' {
+		bo = binary.BigEndian
+	}
+	ifd := int(bo.Uint32(data[4:]))
+	if ifd+2 > len(data) {
+		return nil
+	}
+	count := int(bo.Uint16(data[ifd:]))
+	for e := 0; e < count; e++ {
+		p := ifd + 2 + e*12
+		if p+12 > len(data) {
+			return nil
+		}
+		if bo.Uint16(data[p:]) != 34675 {
+			continue
+		}
+		n := int(bo.Uint32(data[p+4:]))
+		off := int(bo.Uint32(data[p+8:]))
+		if n <= 4 {
+			return data[p+8 : p+8+n]
+		}
+		if off < 0 || off+n > len(data) {
+			return nil
+		}
+		return data[off : off+n]
+	}
+	return nil
+}
+
+// parseICC lit un profil RGB ou gris de type matrice/TRC.
+func parseICC(b []byte) (*iccProfile, error) {
+	if len(b) < 132 || string(b[36:40]) != "acsp" {
+		return nil, errors.New("profil ICC invalide")
+	}
+	space := string(b[16:20])
+	if string(b[20:24]) != "XYZ " {
+		return nil, errors.New("espace de connexion ICC non supporté")
+	}
+
+	tags := map[string][]byte{}
+	count := int(binary.BigEndian.Uint32(b[128:]))
+	for i := 0; i < count; i++ {
+		p := 132 + i*12
+		if p+12 > len(b) {
+			return nil, errors.New("table des tags ICC tronquée")
+		}
+		off := int(binary.BigEndian.Uint32(b[p+4:]))
+		n := int(binary.BigEndian.Uint32(b[p+8:]))
+		if off < 0 || n < 0 || off+n > len(b) {
+			continue
+		}
+		tags[string(b[p:p+4])] = b[off : off+n]
+	}
+
+	p := &iccProfile{}
+	switch space {
+	case "GRAY":
+		p.gray = true
+		c, err := parseCurve(tags["kTRC"])
+		if err != nil {
+			return nil, err
+		}
+		p.trc[0] = c
+	case "RGB ":
+		for i, name := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+			xyz, err := parseXYZ(tags[name])
+			if err != nil {
+				return nil, err
+			}
+			for row := 0; row < 3; row++ {
+				p.matrix[row][i] = xyz[row]
+			}
+		}
+		for i, name := range []string{"rTRC", "gTRC", "bTRC"} {
+			c, err := parseCurve(tags[name])
+			if err != nil {
+				return nil, err
+			}
+			p.trc[i] = c
+		}
+	default:
+		return nil, errors.New("espace couleur ICC non supporté : " + space)
+	}
+	return p, nil
+}
+
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+func parseXYZ(b []byte) ([3]float64, error) {
+	if len(b) < 20 || string(b[:4]) != "XYZ " {
+		return [3]float64{}, errors.New("tag XYZ manquant ou invalide")
+	}
+	return [3]float64{s15Fixed16(b[8:]), s15Fixed16(b[12:]), s15Fixed16(b[16:])}, nil
+}
+
+func parseCurve(b []byte) (toneCurve, error) {
+	c := toneCurve{kind: -1}
+	if len(b) < 12 {
+		return c, errors.New("courbe TRC manquante")
+	}
+	switch string(b[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(b[8:]))
+		switch {
+		case n == 0:
+			c.gamma = 1
+		case n == 1:
+			if len(b) < 14 {
+				return c, errors.New("courbe TRC tronquée")
+			}
+			c.gamma = float64(binary.BigEndian.Uint16(b[12:])) / 256
+		default:
+			if len(b) < 12+2*n {
+				return c, errors.New("courbe TRC tronquée")
+			}
+			c.table = make([]float64, n)
+			for i := range c.table {
+				c.table[i] = float64(binary.BigEndian.Uint16(b[12+2*i:])) / 65535
+			}
+		}
+	case "para":
+		kind := int(binary.BigEndian.Uint16(b[8:]))
+		nparams := [5]int{1, 3, 4, 5, 7}
+		if kind > 4 || len(b) < 12+4*nparams[kind] {
+			return c, errors.New("courbe paramétrique non supportée")
+		}
+		c.kind = kind
+		for i := 0; i < nparams[kind]; i++ {
+			c.params[i] = s15Fixed16(b[12+4*i:])
+		}
+	default:
+		return c, errors.New("type de courbe TRC non supporté")
+	}
+	return c, nil
+}
+
+// eval applique la courbe à x ∈ [0,1].
+func (c *toneCurve) eval(x float64) float64 {
+	if c.table != nil {
+		pos := x * float64(len(c.table)-1)
+		i := int(pos)
+		if i >= len(c.table)-1 {
+			return c.table[len(c.table)-1]
+		}
+		f := pos - float64(i)
+		return c.table[i]*(1-f) + c.table[i+1]*f
+	}
+	if c.kind < 0 {
+		return math.Pow(x, c.gamma)
+	}
+	g, a, b, cc, d, e, f := c.params[0], c.params[1], c.params[2], c.params[3], c.params[4], c.params[5], c.params[6]
+	switch c.kind {
+	case 0:
+		return math.Pow(x, g)
+	case 1:
+		if x >= -b/a {
+			return math.Pow(a*x+b, g)
+		}
+		return 0
+	case 2:
+		if x >= -b/a {
+			return math.Pow(a*x+b, g) + cc
+		}
+		return cc
+	case 3:
+		if x >= d {
+			return math.Pow(a*x+b, g)
+		}
+		return cc * x
+	default:
+		if x >= d {
+			return math.Pow(a*x+b, g) + e
+		}
+		return cc*x + f
+	}
+}
+
+// isSRGB indique si le profil est (à peu près) le sRGB, auquel cas aucune conversion n'est utile.
+func (p *iccProfile) isSRGB() bool {
+	if p.gray {
+		return false
+	}
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			if math.Abs(p.matrix[r][c]-srgbD50[r][c]) > 0.002 {
+				return false
+			}
+		}
+	}
+	for i := range p.trc {
+		for _, x := range []float64{0.05, 0.25, 0.5, 0.75} {
+			if math.Abs(p.trc[i].eval(x)-srgbToLinear(x)) > 0.005 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) float64 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 1 {
+		return 1
+	}
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+func invert3(m [3][3]float64) [3][3]float64 {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	var r [3][3]float64
+	r[0][0] = (m[1][1]*m[2][2] - m[1][2]*m[2][1]) / det
+	r[0][1] = (m[0][2]*m[2][1] - m[0][1]*m[2][2]) / det
+	r[0][2] = (m[0][1]*m[1][2] - m[0][2]*m[1][1]) / det
+	r[1][0] = (m[1][2]*m[2][0] - m[1][0]*m[2][2]) / det
+	r[1][1] = (m[0][0]*m[2][2] - m[0][2]*m[2][0]) / det
+	r[1][2] = (m[0][2]*m[1][0] - m[0][0]*m[1][2]) / det
+	r[2][0] = (m[1][0]*m[2][1] - m[1][1]*m[2][0]) / det
+	r[2][1] = (m[0][1]*m[2][0] - m[0][0]*m[2][1]) / det
+	r[2][2] = (m[0][0]*m[1][1] - m[0][1]*m[1][0]) / det
+	return r
+}
+
+func mul3(a, b [3][3]float64) [3][3]float64 {
+	var r [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				r[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return r
+}
+
+const lutSize = 4096
+
+// buildLUT échantillonne f sur [0,1] pour une évaluation rapide par interpolation.
+func buildLUT(f func(float64) float64) []float64 {
+	lut := make([]float64, lutSize+1)
+	for i := range lut {
+		lut[i] = f(float64(i) / lutSize)
+	}
+	return lut
+}
+
+func lookup(lut []float64, x float64) float64 {
+	if x <= 0 {
+		return lut[0]
+	}
+	if x >= 1 {
+		return lut[lutSize]
+	}
+	pos := x * lutSize
+	i := int(pos)
+	f := pos - float64(i)
+	return lut[i]*(1-f) + lut[i+1]*f
+}
+
+// toSRGB convertit les pixels de img du profil p vers sRGB.
+// La profondeur 16 bits est conservée si l'image source l'utilise.
+func (p *iccProfile) toSRGB(img image.Image) image.Image {
+	b := img.Bounds()
+	deep := isHighDepth(img)
+
+	var in [3][]float64
+	for i := range in {
+		if p.gray && i > 0 {
+			break
+		}
+		in[i] = buildLUT(p.trc[i].eval)
+	}
+	out := buildLUT(linearToSRGB)
+
+	if p.gray {
+		var dst draw.Image
+		if deep {
+			dst = image.NewGray16(b)
+		} else {
+			dst = image.NewGray(b)
+		}
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				g := color.Gray16Model.Convert(img.At(x, y)).(color.Gray16).Y
+				v := lookup(out, lookup(in[0], float64(g)/65535))
+				dst.Set(x, y, color.Gray16{Y: uint16(math.Round(v * 65535))})
+			}
+		}
+		return dst
+	}
+
+	m := mul3(xyzToSRGB, p.matrix)
+	dst := newCanvasLike(img, b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+			r := lookup(in[0], float64(c.R)/65535)
+			g := lookup(in[1], float64(c.G)/65535)
+			bl := lookup(in[2], float64(c.B)/65535)
+			var rgb [3]uint16
+			for i := 0; i < 3; i++ {
+				v := m[i][0]*r + m[i][1]*g + m[i][2]*bl
+				rgb[i] = uint16(math.Round(lookup(out, v) * 65535))
+			}
+			dst.Set(x, y, color.NRGBA64{R: rgb[0], G: rgb[1], B: rgb[2], A: c.A})
+		}
+	}
+	return dst
+}
+
+// applyColorProfile applique la politique de profil couleur.
+// Retourne l'image (éventuellement convertie) et le profil à ré-intégrer dans le fichier final.
+func applyColorProfile(img image.Image, profile []byte, format string, opts *Options) (image.Image, []byte) {
+	if len(profile) == 0 || opts.ColorProfile == ColorProfileIgnore {
+		return img, nil
+	}
+	if opts.ColorProfile == ColorProfileKeep && canEmbedICC(format) {
+		return img, profile
+	}
+
+	p, err := parseICC(profile)
+	if err != nil || p.isSRGB() {
+		// Profil non supporté (LUT…) : on le conserve si possible plutôt que de le perdre,
+		// encodeImage l'écarte s'il ne correspond pas aux pixels écrits (CMYK…)
+		if err != nil && canEmbedICC(format) {
+			return img, profile
+		}
+		return img, nil
+	}
+	if _, ok := img.(*image.CMYK); ok {
+		return img, nil
+	}
+	return p.toSRGB(img), nil
+}
+
+func canEmbedICC(format string) bool {
+	switch format {
+	case "JPEG", "JPG", "PNG", "WEBP":
+		return true
+	}
+	return false
+}
+
+// iccMatchesImage indique si l'espace couleur du profil ('RGB ' ou 'GRAY') est celui des
+// pixels réellement encodés. Un profil CMYK dans un JPEG RVB, ou un profil gris sur une
+// image passée en couleur par une transformation, rendrait le fichier invalide.
+func iccMatchesImage(profile []byte, img image.Image, format string) bool {
+	if len(profile) < 20 {
+		return false
+	}
+	want := "RGB "
+	if isGrayImage(img) && format != "WEBP" { // WebP n'a pas de mode niveaux de gris
+		want = "GRAY"
+	}
+	return string(profile[16:20]) == want
+}
+
+// embedICC ajoute le profil dans les données encodées.
+func embedICC(data []byte, format string, profile []byte) ([]byte, error) {
+	switch format {
+	case "JPEG", "JPG":
+		return embedICCInJPEG(data, profile)
+	case "PNG":
+		return embedICCInPNG(data, profile)
+	case "WEBP":
+		return embedICCInWebP(data, profile)
+	}
+	return data, nil
+}
+
+func embedICCInJPEG(data, profile []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, errors.New("JPEG invalide")
+	}
+	// Insertion après SOI et l'éventuel segment JFIF
+	pos := 2
+	if data[2] == 0xFF && data[3] == 0xE0 {
+		pos += 2 + int(binary.BigEndian.Uint16(data[4:]))
+	}
+
+	const maxChunk = 65533 - 16
+	total := (len(profile) + maxChunk - 1) / maxChunk
+	if total > 255 {
+		return nil, errors.New("profil ICC trop volumineux pour un JPEG")
+	}
+
+	var seg bytes.Buffer
+	for i := 0; i < total; i++ {
+		chunk := profile[i*maxChunk : min((i+1)*maxChunk, len(profile))]
+		n := 2 + 14 + len(chunk)
+		seg.Write([]byte{0xFF, 0xE2, byte(n >> 8), byte(n)})
+		seg.WriteString("ICC_PROFILE\x00")
+		seg.Write([]byte{byte(i + 1), byte(total)})
+		seg.Write(chunk)
+	}
+
+	out := make([]byte, 0, len(data)+seg.Len())
+	out = append(out, data[:pos]...)
+	out = append(out, seg.Bytes()...)
+	return append(out, data[pos:]...), nil
+}
+
+func embedICCInPNG(data, profile []byte) ([]byte, error) {
+	// IHDR fait toujours 25 octets après la signature
+	const pos = 8 + 25
+	if len(data) < pos {
+		return nil, errors.New("PNG invalide")
+	}
+
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	if _, err := zw.Write(profile); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	payload := append([]byte("ICC Profile\x00\x00"), z.Bytes()...)
+	chunk := make([]byte, 8, 12+len(payload))
+	binary.BigEndian.PutUint32(chunk, uint32(len(payload)))
+	copy(chunk[4:], "iCCP")
+	chunk = append(chunk, payload...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	out := make([]byte, 0, len(data)+len(chunk))
+	out = append(out, data[:pos]...)
+	out = append(out, chunk...)
+	return append(out, data[pos:]...), nil
+}
+
+func embedICCInWebP(data, profile []byte) ([]byte, error) {
+	if len(data) < 20 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errors.New("WebP invalide")
+	}
+
+	iccp := riffChunk("ICCP", profile)
+	body := data[12:]
+
+	var out []byte
+	if string(body[0:4]) == "VP8X" {
+		// Format étendu : on active le drapeau ICC et on insère après VP8X
+		ext := append([]byte(nil), body[:18]...)
+		ext[8] |= 0x20
+		out = append(append(ext, iccp...), body[18:]...)
+	} else {
+		w, h, err := webpCanvasSize(body)
+		if err != nil {
+			return nil, err
+		}
+		var flags byte = 0x20
+		if string(body[0:4]) == "VP8L" && len(body) > 12 && body[12]&0x10 != 0 {
+			flags |= 0x10 // alpha
+		}
+		vp8x := make([]byte, 10)
+		vp8x[0] = flags
+		putUint24(vp8x[4:], w-1)
+		putUint24(vp8x[7:], h-1)
+		out = append(append(riffChunk("VP8X", vp8x), iccp...), body...)
+	}
+
+	header := make([]byte, 12)
+	copy(header, "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+len(out)))
+	copy(header[8:], "WEBP")
+	return append(header, out...), nil
+}
+
+func riffChunk(fourcc string, payload []byte) []byte {
+	c := make([]byte, 8, 8+len(payload)+1)
+	copy(c, fourcc)
+	binary.LittleEndian.PutUint32(c[4:], uint32(len(payload)))
+	c = append(c, payload...)
+	if len(payload)%2 == 1 {
+		c = append(c, 0)
+	}
+	return c
+}
+
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
+
+// webpCanvasSize lit les dimensions d'un flux VP8 ou VP8L simple.
+func webpCanvasSize(body []byte) (int, int, error) {
+	switch string(body[0:4]) {
+	case "VP8 ":
+		if len(body) < 18 {
+			break
+		}
+		w := int(binary.LittleEndian.Uint16(body[14:])) & 0x3FFF
+		h := int(binary.LittleEndian.Uint16(body[16:])) & 0x3FFF
+		return w, h, nil
+	case "VP8L":
+		if len(body) < 13 {
+			break
+		}
+		bits := binary.LittleEndian.Uint32(body[9:])
+		return int(bits&0x3FFF) + 1, int(bits>>14&0x3FFF) + 1, nil
+	}
+	return 0, 0, errors.New("flux WebP non reconnu")
+}
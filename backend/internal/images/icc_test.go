@@ -0,0 +1,191 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/chai2010/webp"
+)
+
+// testICC construit un profil matrice/TRC minimal ('RGB ' aux primaires sRGB ou 'GRAY')
+// avec des courbes gamma (0 = courbe paramétrique sRGB) ; padding ajoute un tag inutile
+// pour grossir le profil.
+func testICC(space string, gamma float64, padding int) []byte {
+	type tag struct {
+		sig  string
+		data []byte
+	}
+	xyz := func(v [3]float64) []byte {
+		b := []byte("XYZ \x00\x00\x00\x00")
+		for _, f := range v {
+			b = binary.BigEndian.AppendUint32(b, uint32(int32(math.Round(f*65536))))
+		}
+		return b
+	}
+	curv := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x01")
+	curv = binary.BigEndian.AppendUint16(curv, uint16(math.Round(gamma*256)))
+	if gamma == 0 {
+		curv = []byte("para\x00\x00\x00\x00\x00\x03\x00\x00")
+		for _, v := range []float64{2.4, 1 / 1.055, 0.055 / 1.055, 1 / 12.92, 0.04045} {
+			curv = binary.BigEndian.AppendUint32(curv, uint32(int32(math.Round(v*65536))))
+		}
+	}
+
+	var tags []tag
+	if space == "GRAY" {
+		tags = append(tags, tag{"kTRC", curv})
+	} else {
+		for i, sig := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+			tags = append(tags, tag{sig, xyz([3]float64{srgbD50[0][i], srgbD50[1][i], srgbD50[2][i]})})
+		}
+		for _, sig := range []string{"rTRC", "gTRC", "bTRC"} {
+			tags = append(tags, tag{sig, curv})
+		}
+	}
+	if padding > 0 {
+		tags = append(tags, tag{"zzzz", make([]byte, padding)})
+	}
+
+	header := make([]byte, 128)
+	copy(header[12:], "mntr")
+	copy(header[16:], space)
+	copy(header[20:], "XYZ ")
+	copy(header[36:], "acsp")
+	table := binary.BigEndian.AppendUint32(nil, uint32(len(tags)))
+	offset := 128 + 4 + 12*len(tags)
+	var data []byte
+	for _, t := range tags {
+		table = append(table, t.sig...)
+		table = binary.BigEndian.AppendUint32(table, uint32(offset+len(data)))
+		table = binary.BigEndian.AppendUint32(table, uint32(len(t.data)))
+		data = append(data, t.data...)
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+	}
+	profile := append(append(header, table...), data...)
+	binary.BigEndian.PutUint32(profile, uint32(len(profile)))
+	return profile
+}
+
+func TestEmbedExtractICC(t *testing.T) {
+	img := testPhoto(40, 30)
+	var jpg, pngData bytes.Buffer
+	if err := jpeg.Encode(&jpg, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(&pngData, img); err != nil {
+		t.Fatal(err)
+	}
+	webpData, err := webp.EncodeRGBA(img, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profiles := map[string][]byte{
+		"petit":  testICC("RGB ", 2.2, 0),
+		"grand":  testICC("RGB ", 2.2, 150000), // plusieurs segments APP2 en JPEG
+		"impair": testICC("RGB ", 1.8, 13),
+	}
+	for name, profile := range profiles {
+		for _, f := range []struct {
+			format string
+			data   []byte
+		}{{"JPEG", jpg.Bytes()}, {"PNG", pngData.Bytes()}, {"WEBP", webpData}} {
+			out, err := embedICC(f.data, f.format, profile)
+			if err != nil {
+				t.Fatalf("%s/%s : %v", name, f.format, err)
+			}
+			if got := extractICC(out); !bytes.Equal(got, profile) {
+				t.Errorf("%s/%s : profil relu de %d octets, %d attendus", name, f.format, len(got), len(profile))
+			}
+			if _, _, err := image.Decode(bytes.NewReader(out)); err != nil {
+				t.Errorf("%s/%s : fichier illisible après intégration : %v", name, f.format, err)
+			}
+		}
+	}
+}
+
+func TestParseICCConversion(t *testing.T) {
+	// Profil linéaire (gamma 1) aux primaires sRGB : 50 % linéaire vaut ~188 en sRGB
+	p, err := parseICC(testICC("RGB ", 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.isSRGB() {
+		t.Fatal("profil linéaire pris pour du sRGB")
+	}
+	src := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	src.Set(0, 0, color.NRGBA{128, 128, 128, 255})
+	r, g, b, _ := p.toSRGB(src).At(0, 0).RGBA()
+	for _, v := range []uint32{r >> 8, g >> 8, b >> 8} {
+		if v < 185 || v > 191 {
+			t.Fatalf("gris linéaire converti en (%d,%d,%d), ~188 attendu", r>>8, g>>8, b>>8)
+		}
+	}
+
+	srgb, err := parseICC(testICC("RGB ", 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !srgb.isSRGB() {
+		t.Error("profil sRGB non reconnu")
+	}
+	if _, err := parseICC(testICC("CMYK", 1, 0)); err == nil {
+		t.Error("profil CMYK accepté comme matrice/TRC")
+	}
+}
+
+func TestICCMatchesImage(t *testing.T) {
+	rgb := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	gray := image.NewGray(image.Rect(0, 0, 2, 2))
+	cases := []struct {
+		space  string
+		img    image.Image
+		format string
+		want   bool
+	}{
+		{"RGB ", rgb, "PNG", true},
+		{"CMYK", rgb, "JPEG", false},
+		{"GRAY", gray, "PNG", true},
+		{"GRAY", rgb, "PNG", false},
+		{"RGB ", gray, "JPEG", false},
+		{"RGB ", gray, "WEBP", true}, // WebP est toujours encodé en couleur
+	}
+	for _, c := range cases {
+		if got := iccMatchesImage(testICC(c.space, 2.2, 0), c.img, c.format); got != c.want {
+			t.Errorf("profil %q, image %T, %s : %v, attendu %v", c.space, c.img, c.format, got, c.want)
+		}
+	}
+}
+
+// Un profil conservé n'est réintégré que s'il correspond aux pixels écrits.
+func TestConvertKeepsMatchingProfileOnly(t *testing.T) {
+	var src bytes.Buffer
+	if err := png.Encode(&src, testPhoto(20, 20)); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		space string
+		kept  bool
+	}{{"RGB ", true}, {"CMYK", false}} {
+		profile := testICC(c.space, 1.8, 0)
+		in, err := embedICC(src.Bytes(), "PNG", profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := ConvertFromReader(bytes.NewReader(in), &Options{Format: "png", ColorProfile: ColorProfileKeep})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := extractICC(out) != nil; got != c.kept {
+			t.Errorf("profil %q : intégré = %v, attendu %v", c.space, got, c.kept)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package images
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	PageSizeImage  = "image"  // une page par image, à la taille de l'image
+	PageSizeA4     = "a4"     // 210 × 297 mm
+	PageSizeLetter = "letter" // 8,5 × 11 pouces
+)
+
+// PDFOptions définit l'assemblage d'images en document PDF
+type PDFOptions struct {
+	PageSize    string   // "image" (défaut), "a4" ou "letter"
+	Orientation string   // "auto" (défaut, suit l'image), "portrait" ou "landscape"
+	Margin      *float64 // marge en millimètres pour A4/Letter (nil = 10, 0 = aucune)
+	DPI         int      // résolution maximale des images dans la page (150 par défaut)
+	Grayscale   bool     // images en niveaux de gris, utile pour les tickets et documents
+	Encoding    *Options // qualité, progressif, sous-échantillonnage... (le format est ignoré)
+}
+
+// PDFResult résume le document produit
+type PDFResult struct {
+	Output string `json:"output"`
+	Pages  int    `json:"pages"`
+	Size   int64  `json:"size"`
+}
+
+const pointsPerMM = 72 / 25.4
+
+// ImagesToPDF écrit les images, dans l'ordre donné, dans un PDF d'une page par image.
+// Chaque image est réencodée en JPEG avec les options d'encodage pour maîtriser la taille.
+func ImagesToPDF(paths []string, output string, opts PDFOptions) (*PDFResult, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("aucune image à assembler")
+	}
+	opts.PageSize = strings.ToLower(opts.PageSize)
+	if opts.PageSize == "" {
+		opts.PageSize = PageSizeImage
+	}
+	if opts.PageSize != PageSizeImage && opts.PageSize != PageSizeA4 && opts.PageSize != PageSizeLetter {
+		return nil, fmt.Errorf("format de page '%s' non supporté", opts.PageSize)
+	}
+	margin := 10.0
+	if opts.Margin != nil {
+		margin = max(0, *opts.Margin)
+	}
+	opts.Margin = &margin
+	if opts.DPI <= 0 {
+		opts.DPI = 150
+	}
+
+	encOpts := Options{}
+	if opts.Encoding != nil {
+		encOpts = *opts.Encoding
+	}
+	encOpts.Format = "JPEG"
+	encOpts.BitDepth = 8
+	applyDefaults(&encOpts)
+
+	// Décodage et réencodage en parallèle : seules les données JPEG sont conservées
+	pages := make([]pdfPage, len(paths))
+	_, err := ParallelConvert(paths, func(i int, path string) ([]byte, error) {
+		img, err := decodeFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		page, w, h := pdfPageLayout(img.Bounds(), opts)
+
+		// Pas plus de pixels que ce qu'affiche la page à la résolution demandée
+		scale := float64(opts.DPI) / 72
+		maxW, maxH := int(math.Ceil(w*scale)), int(math.Ceil(h*scale))
+		img = fitInBox(img, maxW, maxH)
+
+		data, err := encodeImage(flattenForPDF(img, opts.Grayscale), nil, "JPEG", &encOpts)
+		if err != nil {
+			return nil, fmt.Errorf("échec encodage '%s' : %w", filepath.Base(path), err)
+		}
+		pi, err := newPDFImage(data)
+		if err != nil {
+			return nil, err
+		}
+
+		page.images = []pdfPlacement{{
+			image: pi,
+			x:     (page.width - w) / 2,
+			y:     (page.height - h) / 2,
+			w:     w,
+			h:     h,
+		}}
+		pages[i] = page
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if dir := filepath.Dir(output); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("impossible de créer le dossier de sortie : %w", err)
+		}
+	}
+	file, err := os.Create(output)
+	if err != nil {
+		return nil, fmt.Errorf("échec création '%s' : %w", output, err)
+	}
+	defer file.Close()
+
+	if err := writePDF(file, pages); err != nil {
+		return nil, fmt.Errorf("échec écriture '%s' : %w", output, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &PDFResult{Output: output, Pages: len(pages), Size: info.Size()}, nil
+}
+
+// pdfPageLayout calcule la page et la taille d'affichage de l'image (en points).
+func pdfPageLayout(b image.Rectangle, opts PDFOptions) (pdfPage, float64, float64) {
+	iw, ih := float64(b.Dx()), float64(b.Dy())
+
+	if opts.PageSize == PageSizeImage {
+		w, h := iw*72/float64(opts.DPI), ih*72/float64(opts.DPI)
+		return pdfPage{width: w, height: h}, w, h
+	}
+
+	pw, ph := 595.28, 841.89
+	if opts.PageSize == PageSizeLetter {
+		pw, ph = 612, 792
+	}
+	landscape := opts.Orientation == "landscape" || (opts.Orientation != "portrait" && iw > ih)
+	if landscape {
+		pw, ph = ph, pw
+	}
+
+	// Ajustement dans la zone utile en conservant les proportions
+	margin := *opts.Margin * pointsPerMM
+	aw, ah := math.Max(1, pw-2*margin), math.Max(1, ph-2*margin)
+	scale := math.Min(aw/iw, ah/ih)
+	return pdfPage{width: pw, height: ph}, iw * scale, ih * scale
+}
+
+// flattenForPDF compose l'image sur un fond blanc (le JPEG n'a pas d'alpha),
+// en niveaux de gris si demandé.
+func flattenForPDF(img image.Image, gray bool) image.Image {
+	b := img.Bounds()
+	if gray {
+		dst := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Over)
+		return dst
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Over)
+	return dst
+}
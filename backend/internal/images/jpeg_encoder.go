@@ -0,0 +1,793 @@
+package images
+
+import (
+	"bufio"
+	"errors"
+	"image"
+	"io"
+	"math"
+	"sort"
+)
+
+// Encodeur JPEG maison : image/jpeg ne sait produire que du baseline 4:2:0
+// avec les tables de Huffman standard. Celui-ci gère le mode progressif,
+// le choix du sous-échantillonnage et l'optimisation des tables.
+
+// Tables de quantification standard (annexe K), en ordre naturel.
+var stdLumaQuant = [64]int{
+	16, 11, 10, 16, 24, 40, 51, 61,
+	12, 12, 14, 19, 26, 58, 60, 55,
+	14, 13, 16, 24, 40, 57, 69, 56,
+	14, 17, 22, 29, 51, 87, 80, 62,
+	18, 22, 37, 56, 68, 109, 103, 77,
+	24, 35, 55, 64, 81, 104, 113, 92,
+	49, 64, 78, 87, 103, 121, 120, 101,
+	72, 92, 95, 98, 112, 100, 103, 99,
+}
+
+var stdChromaQuant = [64]int{
+	17, 18, 24, 47, 99, 99, 99, 99,
+	18, 21, 26, 66, 99, 99, 99, 99,
+	24, 26, 56, 99, 99, 99, 99, 99,
+	47, 66, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+}
+
+// zigzag[i] donne la position naturelle du i-ème coefficient en zigzag.
+var zigzag = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// Tables de Huffman standard (annexe K.3) : nombre de codes par longueur puis symboles.
+type huffSpec struct {
+	counts  [16]byte
+	symbols []byte
+}
+
+var stdHuffSpecs = [4]huffSpec{
+	// DC luminance
+	{
+		[16]byte{0, 1, 5, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	// AC luminance
+	{
+		[16]byte{0, 2, 1, 3, 3, 2, 4, 3, 5, 5, 4, 4, 0, 0, 1, 125},
+		[]byte{
+			0x01, 0x02, 0x03, 0x00, 0x04, 0x11, 0x05, 0x12,
+			0x21, 0x31, 0x41, 0x06, 0x13, 0x51, 0x61, 0x07,
+			0x22, 0x71, 0x14, 0x32, 0x81, 0x91, 0xa1, 0x08,
+			0x23, 0x42, 0xb1, 0xc1, 0x15, 0x52, 0xd1, 0xf0,
+			0x24, 0x33, 0x62, 0x72, 0x82, 0x09, 0x0a, 0x16,
+			0x17, 0x18, 0x19, 0x1a, 0x25, 0x26, 0x27, 0x28,
+			0x29, 0x2a, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39,
+			0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49,
+			0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59,
+			0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69,
+			0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79,
+			0x7a, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89,
+			0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98,
+			0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7,
+			0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6,
+			0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5,
+			0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2, 0xd3, 0xd4,
+			0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda, 0xe1, 0xe2,
+			0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea,
+			0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+	// DC chrominance
+	{
+		[16]byte{0, 3, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	// AC chrominance
+	{
+		[16]byte{0, 2, 1, 2, 4, 4, 3, 4, 7, 5, 4, 4, 0, 1, 2, 119},
+		[]byte{
+			0x00, 0x01, 0x02, 0x03, 0x11, 0x04, 0x05, 0x21,
+			0x31, 0x06, 0x12, 0x41, 0x51, 0x07, 0x61, 0x71,
+			0x13, 0x22, 0x32, 0x81, 0x08, 0x14, 0x42, 0x91,
+			0xa1, 0xb1, 0xc1, 0x09, 0x23, 0x33, 0x52, 0xf0,
+			0x15, 0x62, 0x72, 0xd1, 0x0a, 0x16, 0x24, 0x34,
+			0xe1, 0x25, 0xf1, 0x17, 0x18, 0x19, 0x1a, 0x26,
+			0x27, 0x28, 0x29, 0x2a, 0x35, 0x36, 0x37, 0x38,
+			0x39, 0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48,
+			0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58,
+			0x59, 0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68,
+			0x69, 0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78,
+			0x79, 0x7a, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+			0x88, 0x89, 0x8a, 0x92, 0x93, 0x94, 0x95, 0x96,
+			0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5,
+			0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4,
+			0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3,
+			0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2,
+			0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda,
+			0xe2, 0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9,
+			0xea, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+}
+
+// jpegComponent décrit une composante (Y, Cb ou Cr) et ses coefficients quantifiés.
+type jpegComponent struct {
+	id     byte
+	h, v   int
+	tq     int
+	table  int         // 0 = luminance, 1 = chrominance
+	bw, bh int         // grille de blocs complète (alignée sur les MCU)
+	cw, ch int         // blocs réellement couverts par l'image (scans non entrelacés)
+	coefs  [][64]int16 // quantifiés, dans l'ordre zigzag
+}
+
+// jpegScan décrit un scan : composantes concernées et bande spectrale.
+type jpegScan struct {
+	comps  []int
+	ss, se int
+}
+
+// huffCode associe à chaque symbole son code et sa longueur.
+type huffCode struct {
+	code [256]uint16
+	size [256]byte
+}
+
+// Mémoire de travail de encodeJPEG par pixel : coefficients int16 des trois composantes en
+// 4:4:4 (moins en sous-échantillonné) ; les plans YCbCr ne couvrent qu'une ligne de MCU
+const jpegEncoderBytesPerPixel = 6
+
+// usesCustomJPEG indique si les options demandent l'encodeur interne plutôt que image/jpeg.
+func usesCustomJPEG(opts *Options) bool {
+	return opts != nil && (opts.Progressive || opts.OptimizeCoding || (opts.Subsampling != "" && opts.Subsampling != "420"))
+}
+
+// encodeJPEG encode img en JPEG selon les options avancées (progressif, sous-échantillonnage, optimisation).
+func encodeJPEG(w io.Writer, img image.Image, opts *Options) error {
+	b := img.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 || b.Dx() > 65535 || b.Dy() > 65535 {
+		return errors.New("dimensions invalides pour le JPEG")
+	}
+
+	hmax, vmax := 1, 1
+	gray := isGrayImage(img)
+	if !gray {
+		switch opts.Subsampling {
+		case "444":
+		case "422":
+			hmax = 2
+		case "", "420":
+			hmax, vmax = 2, 2
+		default:
+			return errors.New("sous-échantillonnage inconnu : " + opts.Subsampling)
+		}
+	}
+
+	quant := [2][64]int{scaleQuant(stdLumaQuant, opts.Quality), scaleQuant(stdChromaQuant, opts.Quality)}
+	comps := buildComponents(img, gray, hmax, vmax, &quant)
+
+	var scans []jpegScan
+	switch {
+	case !opts.Progressive && gray:
+		scans = []jpegScan{{[]int{0}, 0, 63}}
+	case !opts.Progressive:
+		scans = []jpegScan{{[]int{0, 1, 2}, 0, 63}}
+	case gray:
+		scans = []jpegScan{{[]int{0}, 0, 0}, {[]int{0}, 1, 5}, {[]int{0}, 6, 63}}
+	default:
+		scans = []jpegScan{
+			{[]int{0, 1, 2}, 0, 0},
+			{[]int{0}, 1, 5},
+			{[]int{2}, 1, 63},
+			{[]int{1}, 1, 63},
+			{[]int{0}, 6, 63},
+		}
+	}
+
+	// Le mode progressif utilise des symboles EOBRUN absents des tables standard :
+	// les tables sont donc toujours optimisées dans ce cas.
+	optimize := opts.OptimizeCoding || opts.Progressive
+
+	bw := bufio.NewWriter(w)
+	e := &jpegWriter{w: bw}
+	e.marker(0xD8)
+	e.writeJFIF()
+	e.writeDQT(&quant, len(comps) > 1)
+	e.writeSOF(b.Dx(), b.Dy(), comps, opts.Progressive)
+
+	if !optimize {
+		var codes [4]huffCode
+		for i := range stdHuffSpecs {
+			codes[i] = buildHuffCode(stdHuffSpecs[i])
+		}
+		for i := range stdHuffSpecs {
+			if len(comps) == 1 && i >= 2 {
+				break
+			}
+			e.writeDHT(byte(i&1), byte(i>>1), stdHuffSpecs[i])
+		}
+		e.writeSOS(comps, scans[0])
+		enc := &huffEncoder{bits: e, codes: &codes}
+		encodeScan(enc, comps, scans[0], hmax, vmax)
+		e.flushBits()
+	} else {
+		for _, sc := range scans {
+			// Premier passage : comptage des symboles
+			counter := &huffCounter{}
+			encodeScan(counter, comps, sc, hmax, vmax)
+
+			var codes [4]huffCode
+			for i := range counter.freq {
+				if !counter.used[i] {
+					continue
+				}
+				spec := buildHuffSpec(counter.freq[i][:])
+				codes[i] = buildHuffCode(spec)
+				e.writeDHT(byte(i&1), byte(i>>1), spec)
+			}
+
+			// Second passage : écriture réelle
+			e.writeSOS(comps, sc)
+			enc := &huffEncoder{bits: e, codes: &codes}
+			encodeScan(enc, comps, sc, hmax, vmax)
+			e.flushBits()
+		}
+	}
+
+	e.marker(0xD9)
+	if e.err != nil {
+		return e.err
+	}
+	return bw.Flush()
+}
+
+// isGrayImage indique si l'image ne contient qu'une composante de luminance.
+func isGrayImage(img image.Image) bool {
+	switch img.(type) {
+	case *image.Gray, *image.Gray16:
+		return true
+	}
+	return false
+}
+
+// scaleQuant applique le facteur de qualité (formule IJG) à une table standard.
+func scaleQuant(base [64]int, quality int) [64]int {
+	if quality < 1 {
+		quality = 1
+	} else if quality > 100 {
+		quality = 100
+	}
+	scale := 200 - quality*2
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+	var q [64]int
+	for i, v := range base {
+		x := (v*scale + 50) / 100
+		if x < 1 {
+			x = 1
+		} else if x > 255 {
+			x = 255
+		}
+		q[i] = x
+	}
+	return q
+}
+
+// buildComponents convertit l'image en YCbCr et calcule les coefficients DCT quantifiés de
+// chaque bloc. Le calcul se fait par ligne de MCU : seuls les coefficients de l'image entière
+// sont conservés, pour les passages successifs (tables optimisées, mode progressif).
+func buildComponents(img image.Image, gray bool, hmax, vmax int, quant *[2][64]int) []*jpegComponent {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	mcuW, mcuH := 8*hmax, 8*vmax
+	pw := (w + mcuW - 1) / mcuW * mcuW
+	ph := (h + mcuH - 1) / mcuH * mcuH
+
+	planes := 3
+	if gray {
+		planes = 1
+	}
+	comps := make([]*jpegComponent, planes)
+	for p := range comps {
+		c := &jpegComponent{id: byte(p + 1), h: 1, v: 1}
+		if p == 0 {
+			c.h, c.v = hmax, vmax
+		} else {
+			c.tq, c.table = 1, 1
+		}
+		c.bw, c.bh = pw*c.h/hmax/8, ph*c.v/vmax/8
+		c.cw = ((w*c.h+hmax-1)/hmax + 7) / 8
+		c.ch = ((h*c.v+vmax-1)/vmax + 7) / 8
+		c.coefs = make([][64]int16, c.bw*c.bh)
+		comps[p] = c
+	}
+
+	// Plans d'une ligne de MCU, et chrominance sous-échantillonnée
+	band := make([][]float32, planes)
+	for i := range band {
+		band[i] = make([]float32, pw*mcuH)
+	}
+	var small []float32
+	if planes > 1 && (hmax > 1 || vmax > 1) {
+		small = make([]float32, pw/hmax*8)
+	}
+
+	for my := 0; my < ph/mcuH; my++ {
+		// Extraction avec réplication des bords dans la zone de remplissage
+		for y := 0; y < mcuH; y++ {
+			sy := min(my*mcuH+y, h-1)
+			for x := 0; x < pw; x++ {
+				sx := min(x, w-1)
+				r, g, bl, _ := img.At(b.Min.X+sx, b.Min.Y+sy).RGBA()
+				rf, gf, bf := float32(r>>8), float32(g>>8), float32(bl>>8)
+				i := y*pw + x
+				if gray {
+					band[0][i] = rf
+					continue
+				}
+				band[0][i] = 0.299*rf + 0.587*gf + 0.114*bf
+				band[1][i] = -0.168736*rf - 0.331264*gf + 0.5*bf + 128
+				band[2][i] = 0.5*rf - 0.418688*gf - 0.081312*bf + 128
+			}
+		}
+
+		for p, c := range comps {
+			plane, planeW := band[p], pw
+			if small != nil && p > 0 {
+				downsample(small, plane, pw, mcuH, hmax, vmax)
+				plane, planeW = small, pw/hmax
+			}
+			transformBlocks(c, plane, planeW, my*c.v, &quant[c.tq])
+		}
+	}
+	return comps
+}
+
+// transformBlocks calcule les coefficients quantifiés des c.v lignes de blocs d'une bande,
+// à partir de la ligne de blocs firstRow.
+func transformBlocks(c *jpegComponent, plane []float32, planeW, firstRow int, q *[64]int) {
+	var block [64]float64
+	for by := 0; by < c.v; by++ {
+		for bx := 0; bx < c.bw; bx++ {
+			for y := 0; y < 8; y++ {
+				row := (by*8+y)*planeW + bx*8
+				for x := 0; x < 8; x++ {
+					block[y*8+x] = float64(plane[row+x]) - 128
+				}
+			}
+			fdct(&block)
+			out := &c.coefs[(firstRow+by)*c.bw+bx]
+			for k := 0; k < 64; k++ {
+				n := zigzag[k]
+				out[k] = int16(math.Round(block[n] / float64(q[n])))
+			}
+		}
+	}
+}
+
+// downsample réduit un plan w×h dans dst par moyenne de blocs fx×fy.
+func downsample(dst, src []float32, w, h, fx, fy int) {
+	dw, dh := w/fx, h/fy
+	n := float32(fx * fy)
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sum float32
+			for j := 0; j < fy; j++ {
+				row := (y*fy+j)*w + x*fx
+				for i := 0; i < fx; i++ {
+					sum += src[row+i]
+				}
+			}
+			dst[y*dw+x] = sum / n
+		}
+	}
+}
+
+var dctCos = func() (t [8][8]float64) {
+	for x := 0; x < 8; x++ {
+		for u := 0; u < 8; u++ {
+			t[x][u] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / 16)
+		}
+	}
+	return
+}()
+
+// fdct calcule la DCT 2D d'un bloc 8×8 (séparable lignes puis colonnes).
+func fdct(b *[64]float64) {
+	var tmp [64]float64
+	for y := 0; y < 8; y++ {
+		for u := 0; u < 8; u++ {
+			var s float64
+			for x := 0; x < 8; x++ {
+				s += b[y*8+x] * dctCos[x][u]
+			}
+			if u == 0 {
+				s *= math.Sqrt2 / 2
+			}
+			tmp[y*8+u] = s / 2
+		}
+	}
+	for u := 0; u < 8; u++ {
+		for v := 0; v < 8; v++ {
+			var s float64
+			for y := 0; y < 8; y++ {
+				s += tmp[y*8+u] * dctCos[y][v]
+			}
+			if v == 0 {
+				s *= math.Sqrt2 / 2
+			}
+			b[v*8+u] = s / 2
+		}
+	}
+}
+
+// symbolSink reçoit les symboles d'un scan : comptage ou écriture.
+type symbolSink interface {
+	symbol(table int, sym byte)
+	extra(value uint32, n int)
+}
+
+// huffCounter compte la fréquence des symboles pour optimiser les tables.
+// Index des tables : 0 = DC lum, 1 = AC lum, 2 = DC chroma, 3 = AC chroma.
+type huffCounter struct {
+	freq [4][256]int
+	used [4]bool
+}
+
+func (c *huffCounter) symbol(table int, sym byte) {
+	c.freq[table][sym]++
+	c.used[table] = true
+}
+
+func (c *huffCounter) extra(uint32, int) {}
+
+// huffEncoder écrit les symboles avec les tables fournies.
+type huffEncoder struct {
+	bits  *jpegWriter
+	codes *[4]huffCode
+}
+
+func (h *huffEncoder) symbol(table int, sym byte) {
+	c := &h.codes[table]
+	h.bits.writeBits(uint32(c.code[sym]), int(c.size[sym]))
+}
+
+func (h *huffEncoder) extra(value uint32, n int) {
+	if n > 0 {
+		h.bits.writeBits(value, n)
+	}
+}
+
+// bitLength renvoie la catégorie (nombre de bits) et la représentation d'une valeur.
+func bitLength(v int32) (int, uint32) {
+	a := v
+	if a < 0 {
+		a = -a
+		v--
+	}
+	n := 0
+	for a > 0 {
+		n++
+		a >>= 1
+	}
+	return n, uint32(v) & (1<<uint(n) - 1)
+}
+
+// encodeScan émet les symboles d'un scan (baseline ou progressif sans approximations successives).
+func encodeScan(sink symbolSink, comps []*jpegComponent, sc jpegScan, hmax, vmax int) {
+	pred := make([]int32, len(comps))
+	dcTable := func(c *jpegComponent) int { return c.table * 2 }
+	acTable := func(c *jpegComponent) int { return c.table*2 + 1 }
+
+	eobrun := 0
+	flushEOB := func(c *jpegComponent) {
+		if eobrun == 0 {
+			return
+		}
+		n := 0
+		for r := eobrun; r > 1; r >>= 1 {
+			n++
+		}
+		sink.symbol(acTable(c), byte(n<<4))
+		sink.extra(uint32(eobrun)&(1<<uint(n)-1), n)
+		eobrun = 0
+	}
+
+	codeBlock := func(ci int, blk *[64]int16) {
+		c := comps[ci]
+		if sc.ss == 0 {
+			diff := int32(blk[0]) - pred[ci]
+			pred[ci] = int32(blk[0])
+			n, bits := bitLength(diff)
+			sink.symbol(dcTable(c), byte(n))
+			sink.extra(bits, n)
+		}
+		if sc.se == 0 {
+			return
+		}
+		start := sc.ss
+		if start == 0 {
+			start = 1
+		}
+		run := 0
+		for k := start; k <= sc.se; k++ {
+			v := int32(blk[k])
+			if v == 0 {
+				run++
+				continue
+			}
+			flushEOB(c)
+			for run > 15 {
+				sink.symbol(acTable(c), 0xF0)
+				run -= 16
+			}
+			n, bits := bitLength(v)
+			sink.symbol(acTable(c), byte(run<<4|n))
+			sink.extra(bits, n)
+			run = 0
+		}
+		if run > 0 {
+			if sc.ss == 0 {
+				// Baseline : EOB simple
+				sink.symbol(acTable(c), 0x00)
+			} else {
+				eobrun++
+				if eobrun == 0x7FFF {
+					flushEOB(c)
+				}
+			}
+		}
+	}
+
+	if len(sc.comps) == 1 {
+		ci := sc.comps[0]
+		c := comps[ci]
+		for by := 0; by < c.ch; by++ {
+			for bx := 0; bx < c.cw; bx++ {
+				codeBlock(ci, &c.coefs[by*c.bw+bx])
+			}
+		}
+		flushEOB(c)
+		return
+	}
+
+	mcusX := comps[0].bw / hmax
+	mcusY := comps[0].bh / vmax
+	for my := 0; my < mcusY; my++ {
+		for mx := 0; mx < mcusX; mx++ {
+			for _, ci := range sc.comps {
+				c := comps[ci]
+				for v := 0; v < c.v; v++ {
+					for h := 0; h < c.h; h++ {
+						codeBlock(ci, &c.coefs[(my*c.v+v)*c.bw+mx*c.h+h])
+					}
+				}
+			}
+		}
+	}
+}
+
+// buildHuffSpec construit une table de Huffman optimale (annexe K.2), longueurs limitées à 16 bits.
+func buildHuffSpec(freqIn []int) huffSpec {
+	var freq [257]int
+	copy(freq[:], freqIn)
+	freq[256] = 1 // réserve un code pour qu'aucun code ne soit composé uniquement de 1
+
+	var codesize [257]int
+	var others [257]int
+	for i := range others {
+		others[i] = -1
+	}
+
+	for {
+		c1, c2 := -1, -1
+		for i := 0; i <= 256; i++ {
+			if freq[i] == 0 {
+				continue
+			}
+			if c1 < 0 || freq[i] <= freq[c1] {
+				c1 = i
+			}
+		}
+		for i := 0; i <= 256; i++ {
+			if freq[i] == 0 || i == c1 {
+				continue
+			}
+			if c2 < 0 || freq[i] <= freq[c2] {
+				c2 = i
+			}
+		}
+		if c2 < 0 {
+			break
+		}
+
+		freq[c1] += freq[c2]
+		freq[c2] = 0
+
+		codesize[c1]++
+		for others[c1] >= 0 {
+			c1 = others[c1]
+			codesize[c1]++
+		}
+		others[c1] = c2
+
+		codesize[c2]++
+		for others[c2] >= 0 {
+			c2 = others[c2]
+			codesize[c2]++
+		}
+	}
+
+	var bits [33]int
+	for i := 0; i <= 256; i++ {
+		if codesize[i] > 0 {
+			bits[codesize[i]]++
+		}
+	}
+
+	// Ajustement pour limiter les longueurs à 16 bits
+	for i := 32; i > 16; i-- {
+		for bits[i] > 0 {
+			j := i - 2
+			for bits[j] == 0 {
+				j--
+			}
+			bits[i] -= 2
+			bits[i-1]++
+			bits[j+1] += 2
+			bits[j]--
+		}
+	}
+	// Retrait du code réservé
+	i := 16
+	for bits[i] == 0 {
+		i--
+	}
+	bits[i]--
+
+	var spec huffSpec
+	for l := 1; l <= 16; l++ {
+		spec.counts[l-1] = byte(bits[l])
+	}
+
+	type symLen struct {
+		sym, size int
+	}
+	var syms []symLen
+	for s := 0; s < 256; s++ {
+		if codesize[s] > 0 {
+			syms = append(syms, symLen{s, codesize[s]})
+		}
+	}
+	sort.SliceStable(syms, func(a, b int) bool { return syms[a].size < syms[b].size })
+	for _, s := range syms {
+		spec.symbols = append(spec.symbols, byte(s.sym))
+	}
+	return spec
+}
+
+// buildHuffCode génère les codes canoniques d'une table.
+func buildHuffCode(spec huffSpec) huffCode {
+	var hc huffCode
+	code := uint16(0)
+	k := 0
+	for l := 1; l <= 16; l++ {
+		for n := 0; n < int(spec.counts[l-1]); n++ {
+			sym := spec.symbols[k]
+			hc.code[sym] = code
+			hc.size[sym] = byte(l)
+			code++
+			k++
+		}
+		code <<= 1
+	}
+	return hc
+}
+
+// jpegWriter écrit les segments et le flux de bits (avec bourrage des 0xFF).
+type jpegWriter struct {
+	w     *bufio.Writer
+	err   error
+	acc   uint32
+	nbits int
+}
+
+func (e *jpegWriter) write(p []byte) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.Write(p)
+}
+
+func (e *jpegWriter) marker(m byte) {
+	e.write([]byte{0xFF, m})
+}
+
+func (e *jpegWriter) segment(m byte, payload []byte) {
+	n := len(payload) + 2
+	e.write([]byte{0xFF, m, byte(n >> 8), byte(n)})
+	e.write(payload)
+}
+
+func (e *jpegWriter) writeJFIF() {
+	e.segment(0xE0, []byte{'J', 'F', 'I', 'F', 0, 1, 1, 0, 0, 1, 0, 1, 0, 0})
+}
+
+func (e *jpegWriter) writeDQT(quant *[2][64]int, chroma bool) {
+	tables := 1
+	if chroma {
+		tables = 2
+	}
+	var p []byte
+	for t := 0; t < tables; t++ {
+		p = append(p, byte(t))
+		for k := 0; k < 64; k++ {
+			p = append(p, byte(quant[t][zigzag[k]]))
+		}
+	}
+	e.segment(0xDB, p)
+}
+
+func (e *jpegWriter) writeSOF(w, h int, comps []*jpegComponent, progressive bool) {
+	m := byte(0xC0)
+	if progressive {
+		m = 0xC2
+	}
+	p := []byte{8, byte(h >> 8), byte(h), byte(w >> 8), byte(w), byte(len(comps))}
+	for _, c := range comps {
+		p = append(p, c.id, byte(c.h<<4|c.v), byte(c.tq))
+	}
+	e.segment(m, p)
+}
+
+// writeDHT écrit une table ; class 0 = DC, 1 = AC.
+func (e *jpegWriter) writeDHT(class, id byte, spec huffSpec) {
+	p := []byte{class<<4 | id}
+	p = append(p, spec.counts[:]...)
+	p = append(p, spec.symbols...)
+	e.segment(0xC4, p)
+}
+
+func (e *jpegWriter) writeSOS(comps []*jpegComponent, sc jpegScan) {
+	p := []byte{byte(len(sc.comps))}
+	for _, ci := range sc.comps {
+		c := comps[ci]
+		p = append(p, c.id, byte(c.table<<4|c.table))
+	}
+	p = append(p, byte(sc.ss), byte(sc.se), 0)
+	e.segment(0xDA, p)
+}
+
+func (e *jpegWriter) writeBits(v uint32, n int) {
+	e.acc = e.acc<<uint(n) | v&(1<<uint(n)-1)
+	e.nbits += n
+	for e.nbits >= 8 {
+		b := byte(e.acc >> uint(e.nbits-8))
+		e.nbits -= 8
+		e.write([]byte{b})
+		if b == 0xFF {
+			e.write([]byte{0})
+		}
+	}
+	e.acc &= 1<<uint(e.nbits) - 1
+}
+
+// flushBits complète le dernier octet avec des 1.
+func (e *jpegWriter) flushBits() {
+	if e.nbits > 0 {
+		e.writeBits(0x7F, 8-e.nbits)
+	}
+	e.acc, e.nbits = 0, 0
+}
@@ -0,0 +1,126 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"testing"
+)
+
+// testPhoto est une image synthétique avec dégradés et détails, de taille non multiple de 16.
+func testPhoto(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{
+				R: uint8(x * 255 / w),
+				G: uint8(y * 255 / h),
+				B: uint8(128 + 100*math.Sin(float64(x)/9)*math.Cos(float64(y)/13)),
+				A: 255,
+			})
+		}
+	}
+	return img
+}
+
+// psnr compare deux images sur les canaux RGB, en dB.
+func psnr(a, b image.Image) float64 {
+	r := a.Bounds()
+	var se float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			r1, g1, b1, _ := a.At(x, y).RGBA()
+			r2, g2, b2, _ := b.At(x, y).RGBA()
+			for _, d := range []float64{
+				float64(r1>>8) - float64(r2>>8),
+				float64(g1>>8) - float64(g2>>8),
+				float64(b1>>8) - float64(b2>>8),
+			} {
+				se += d * d
+			}
+		}
+	}
+	mse := se / float64(3*r.Dx()*r.Dy())
+	if mse == 0 {
+		return math.Inf(1)
+	}
+	return 10 * math.Log10(255*255/mse)
+}
+
+func TestEncodeJPEGRoundTrip(t *testing.T) {
+	src := testPhoto(203, 131)
+	gray := image.NewGray(src.Bounds())
+	for y := 0; y < 131; y++ {
+		for x := 0; x < 203; x++ {
+			gray.Set(x, y, src.At(x, y))
+		}
+	}
+
+	cases := []struct {
+		name string
+		img  image.Image
+		opts Options
+		sof  byte // marqueur SOF attendu : 0xC0 baseline, 0xC2 progressif
+	}{
+		{"baseline-444", src, Options{Quality: 90, Subsampling: "444"}, 0xC0},
+		{"baseline-422", src, Options{Quality: 90, Subsampling: "422"}, 0xC0},
+		{"optimise-420", src, Options{Quality: 90, OptimizeCoding: true}, 0xC0},
+		{"progressif-420", src, Options{Quality: 90, Progressive: true}, 0xC2},
+		{"progressif-444", src, Options{Quality: 90, Progressive: true, Subsampling: "444"}, 0xC2},
+		{"gris-progressif", gray, Options{Quality: 90, Progressive: true}, 0xC2},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		if err := encodeJPEG(&buf, c.img, &c.opts); err != nil {
+			t.Fatalf("%s : %v", c.name, err)
+		}
+		if !bytes.Contains(buf.Bytes(), []byte{0xFF, c.sof}) {
+			t.Errorf("%s : marqueur SOF %X absent", c.name, c.sof)
+		}
+		dec, err := jpeg.Decode(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%s : décodage impossible : %v", c.name, err)
+		}
+		if dec.Bounds().Size() != c.img.Bounds().Size() {
+			t.Fatalf("%s : taille %v, attendu %v", c.name, dec.Bounds().Size(), c.img.Bounds().Size())
+		}
+		if p := psnr(c.img, dec); p < 38 {
+			t.Errorf("%s : PSNR %.1f dB, 38 dB minimum attendus", c.name, p)
+		}
+	}
+}
+
+// Les tables optimisées ne changent que le codage entropique : pixels identiques, fichier plus petit.
+func TestEncodeJPEGOptimizedTables(t *testing.T) {
+	src := testPhoto(160, 120)
+	var std, opt bytes.Buffer
+	if err := encodeJPEG(&std, src, &Options{Quality: 85, Subsampling: "444"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := encodeJPEG(&opt, src, &Options{Quality: 85, Subsampling: "444", OptimizeCoding: true}); err != nil {
+		t.Fatal(err)
+	}
+	if opt.Len() >= std.Len() {
+		t.Errorf("tables optimisées : %d octets, tables standard : %d", opt.Len(), std.Len())
+	}
+	a, err := jpeg.Decode(&std)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := jpeg.Decode(&opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := psnr(a, b); !math.IsInf(p, 1) {
+		t.Errorf("pixels différents entre tables standard et optimisées (PSNR %.1f dB)", p)
+	}
+}
+
+func TestEncodeJPEGInvalidSubsampling(t *testing.T) {
+	var buf bytes.Buffer
+	if err := encodeJPEG(&buf, testPhoto(16, 16), &Options{Quality: 80, Subsampling: "411"}); err == nil {
+		t.Error("sous-échantillonnage inconnu accepté")
+	}
+}
@@ -12,6 +12,40 @@ type Options struct {
 	Lossless     bool                 // WebP, AVIF
 	PNGLevel     png.CompressionLevel // PNG compression 0–9
 	TIFFCompress tiff.CompressionType // TIFF compression (Deflate, LZW…)
+
+	Progressive    bool   // JPEG progressif
+	Subsampling    string // JPEG : "444", "422" ou "420" (défaut)
+	OptimizeCoding bool   // JPEG : tables de Huffman optimisées (forcé en progressif)
+
+	ColorProfile string // profil ICC : "srgb" (défaut, conversion), "keep" (conservé) ou "ignore"
+
+	BitDepth int  // 0 = profondeur source conservée si le format le permet (PNG, TIFF), 8 = réduction forcée
+	Dither   bool // tramage Floyd-Steinberg lors d'une réduction 16 → 8 bits
+
+	Crop        string // recadrage : "" (aucun), "rect", "aspect" ou "smart"
+	CropX       int    // mode "rect" : origine et taille du rectangle
+	CropY       int
+	CropWidth   int
+	CropHeight  int
+	AspectRatio string // modes "aspect" et "smart" : "1:1", "4:5", "16:9"…
+
+	IgnoreOrientation bool    // n'applique pas l'orientation EXIF de la source
+	Rotate            float64 // rotation en degrés, sens horaire (angle libre accepté)
+	RotateKeepSize    bool    // angle libre : garde la taille d'origine au lieu d'agrandir le canevas
+	FillColor         string  // angle libre : couleur des zones découvertes (#RRGGBB[AA], transparent par défaut)
+	FlipH             bool    // miroir horizontal
+	FlipV             bool    // miroir vertical
+
+	Trim          bool // retire les bordures uniformes
+	TrimTolerance int  // écart maximal par canal (0–255) pour considérer un pixel comme bordure
+	PadWidth      int  // centre l'image sur un canevas PadWidth×PadHeight (0 = désactivé)
+	PadHeight     int
+	PadColor      string // couleur du canevas (#RRGGBB[AA], blanc par défaut)
+
+	Transparency          string // suppression de fond : "" (aucune), "color" ou "flood" (depuis les coins)
+	TransparentColor      string // mode "color" : couleur rendue transparente (#RRGGBB, blanc par défaut)
+	TransparencyTolerance int    // écart maximal par canal (0–255) pour considérer un pixel comme fond
+	Feather               int    // largeur du fondu (0–255) au-delà de la tolérance, pour adoucir les bords
 }
 
 func applyDefaults(opts *Options) *Options {
@@ -22,6 +56,7 @@ func applyDefaults(opts *Options) *Options {
 			Lossless:     false,
 			PNGLevel:     png.DefaultCompression,
 			TIFFCompress: tiff.Deflate,
+			ColorProfile: ColorProfileSRGB,
 		}
 	}
 
@@ -34,6 +69,9 @@ func applyDefaults(opts *Options) *Options {
 	if opts.TIFFCompress == 0 {
 		opts.TIFFCompress = tiff.Deflate
 	}
+	if opts.ColorProfile == "" {
+		opts.ColorProfile = ColorProfileSRGB
+	}
 
 	return opts
 }
@@ -0,0 +1,204 @@
+package images
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PaletteOptions définit l'extraction des couleurs dominantes
+type PaletteOptions struct {
+	Colors       int    // nombre de couleurs à extraire (5 par défaut)
+	SwatchDir    string // si renseigné, écrit un nuancier <nom>-palette.png dans ce dossier
+	SwatchWidth  int    // largeur du nuancier (500 par défaut)
+	SwatchHeight int    // hauteur du nuancier (100 par défaut)
+}
+
+// PaletteColor est une couleur dominante et sa part dans l'image
+type PaletteColor struct {
+	Hex        string  `json:"hex"`
+	R          uint8   `json:"r"`
+	G          uint8   `json:"g"`
+	B          uint8   `json:"b"`
+	Percentage float64 `json:"percentage"`
+}
+
+// Palette regroupe les couleurs dominantes d'une image, de la plus présente à la moins présente
+type Palette struct {
+	Source string         `json:"source"`
+	Colors []PaletteColor `json:"colors"`
+	Swatch string         `json:"swatch,omitempty"`
+}
+
+// ExtractPaletteFromFile extrait les couleurs dominantes d'un fichier et écrit le nuancier si demandé.
+func ExtractPaletteFromFile(path string, opts PaletteOptions) (*Palette, error) {
+	img, err := decodeFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Palette{Source: path, Colors: ExtractPalette(img, opts.Colors)}
+	if opts.SwatchDir == "" {
+		return p, nil
+	}
+
+	if err := os.MkdirAll(opts.SwatchDir, 0o755); err != nil {
+		return nil, fmt.Errorf("impossible de créer le dossier de sortie : %w", err)
+	}
+	data, err := encodeImage(renderSwatch(p.Colors, opts.SwatchWidth, opts.SwatchHeight), nil, "PNG", applyDefaults(&Options{Format: "PNG"}))
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + "-palette.png"
+	p.Swatch = filepath.Join(opts.SwatchDir, name)
+	if err := os.WriteFile(p.Swatch, data, 0o644); err != nil {
+		return nil, fmt.Errorf("échec écriture '%s' : %w", p.Swatch, err)
+	}
+	return p, nil
+}
+
+// ExtractPalette regroupe les pixels de l'image en k couleurs (k-means sur une miniature)
+// et les trie par proportion décroissante. Les pixels transparents sont ignorés.
+func ExtractPalette(img image.Image, k int) []PaletteColor {
+	if k <= 0 {
+		k = 5
+	}
+
+	px := fitWithin(img, 200)
+	var points [][3]float64
+	for i := 0; i < len(px.Pix); i += 4 {
+		if px.Pix[i+3] < 128 {
+			continue
+		}
+		points = append(points, [3]float64{float64(px.Pix[i]), float64(px.Pix[i+1]), float64(px.Pix[i+2])})
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	centers := kmeansInit(points, k)
+	assign := make([]int, len(points))
+	for iter := 0; iter < 20; iter++ {
+		changed := false
+		for i, p := range points {
+			best := nearestCenter(p, centers)
+			if best != assign[i] || iter == 0 {
+				assign[i] = best
+				changed = true
+			}
+		}
+
+		sums := make([][4]float64, len(centers))
+		for i, p := range points {
+			s := &sums[assign[i]]
+			s[0] += p[0]
+			s[1] += p[1]
+			s[2] += p[2]
+			s[3]++
+		}
+		for c, s := range sums {
+			if s[3] > 0 {
+				centers[c] = [3]float64{s[0] / s[3], s[1] / s[3], s[2] / s[3]}
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	counts := make([]int, len(centers))
+	for _, a := range assign {
+		counts[a]++
+	}
+	var colors []PaletteColor
+	for c, center := range centers {
+		if counts[c] == 0 {
+			continue
+		}
+		r, g, b := uint8(math.Round(center[0])), uint8(math.Round(center[1])), uint8(math.Round(center[2]))
+		colors = append(colors, PaletteColor{
+			Hex:        fmt.Sprintf("#%02x%02x%02x", r, g, b),
+			R:          r,
+			G:          g,
+			B:          b,
+			Percentage: math.Round(float64(counts[c])*10000/float64(len(points))) / 100,
+		})
+	}
+	sort.SliceStable(colors, func(i, j int) bool { return colors[i].Percentage > colors[j].Percentage })
+	return colors
+}
+
+// kmeansInit choisit les centres de départ selon k-means++ avec une graine fixe,
+// pour qu'une même image donne toujours la même palette.
+func kmeansInit(points [][3]float64, k int) [][3]float64 {
+	rng := rand.New(rand.NewSource(1))
+	centers := [][3]float64{points[rng.Intn(len(points))]}
+	dist := make([]float64, len(points))
+	for len(centers) < k {
+		total := 0.0
+		for i, p := range points {
+			dist[i] = colorDist2(p, centers[nearestCenter(p, centers)])
+			total += dist[i]
+		}
+		if total == 0 {
+			break // moins de couleurs distinctes que demandé
+		}
+		target := rng.Float64() * total
+		i := 0
+		for ; i < len(points)-1 && target > dist[i]; i++ {
+			target -= dist[i]
+		}
+		centers = append(centers, points[i])
+	}
+	return centers
+}
+
+func nearestCenter(p [3]float64, centers [][3]float64) int {
+	best, bestDist := 0, math.MaxFloat64
+	for c, center := range centers {
+		if d := colorDist2(p, center); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+func colorDist2(a, b [3]float64) float64 {
+	dr, dg, db := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dr*dr + dg*dg + db*db
+}
+
+// renderSwatch dessine les couleurs en bandes verticales proportionnelles à leur présence.
+func renderSwatch(colors []PaletteColor, width, height int) image.Image {
+	if width <= 0 {
+		width = 500
+	}
+	if height <= 0 {
+		height = 100
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	total := 0.0
+	for _, c := range colors {
+		total += c.Percentage
+	}
+	x, acc := 0, 0.0
+	for i, c := range colors {
+		acc += c.Percentage
+		next := int(math.Round(acc / total * float64(width)))
+		if i == len(colors)-1 {
+			next = width
+		}
+		fill := image.NewUniform(color.NRGBA{R: c.R, G: c.G, B: c.B, A: 255})
+		draw.Draw(dst, image.Rect(x, 0, next, height), fill, image.Point{}, draw.Src)
+		x = next
+	}
+	return dst
+}
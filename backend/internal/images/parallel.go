@@ -2,15 +2,66 @@ package images
 
 import (
 	"fmt"
+	"image"
+	"image/color"
+	"os"
 	"runtime"
+	"strings"
 	"sync"
 )
 
+// ParallelLimits borne le travail simultané de ParallelConvert
+type ParallelLimits struct {
+	Workers      int   `json:"workers"`       // nombre de workers (0 = nb CPU, 8 au maximum)
+	MemoryBudget int64 `json:"memory_budget"` // mémoire estimée des images en cours, en octets (0 = illimitée)
+}
+
+// Budget par défaut : assez pour plusieurs photos courantes, pas pour dix TIFF de 100 Mpx
+const defaultMemoryBudget = 2 << 30
+
+var (
+	limitsMu sync.RWMutex
+	limits   = ParallelLimits{MemoryBudget: defaultMemoryBudget}
+
+	// Budget partagé par tous les traitements en cours, pour que la limite vaille pour le processus
+	budget = newMemoryBudget()
+)
+
+// SetParallelLimits change le nombre de workers et le budget mémoire des traitements suivants.
+func SetParallelLimits(l ParallelLimits) {
+	limitsMu.Lock()
+	limits = l
+	limitsMu.Unlock()
+	budget.cond.Broadcast() // les éléments en attente peuvent tenir dans le nouveau budget
+}
+
+// GetParallelLimits retourne les limites courantes.
+func GetParallelLimits() ParallelLimits {
+	limitsMu.RLock()
+	defer limitsMu.RUnlock()
+	return limits
+}
+
+// ParallelConvert traite les éléments en parallèle. Seuls les chemins d'image (string) sont
+// comptés dans le budget mémoire ; les autres éléments passent hors budget, à moins d'utiliser
+// ParallelConvertWithCost.
 func ParallelConvert[T any](items []T, fn func(int, T) ([]byte, error)) ([][]byte, error) {
+	return ParallelConvertWithCost(items, func(item T) int64 { return estimateMemory(item) }, fn)
+}
+
+// ParallelConvertWithCost est ParallelConvert avec une estimation de la mémoire de chaque
+// élément, en octets (0 = hors budget). Le budget étant commun à tout le processus, fn ne
+// doit pas lancer elle-même un ParallelConvert.
+func ParallelConvertWithCost[T any](items []T, cost func(T) int64, fn func(int, T) ([]byte, error)) ([][]byte, error) {
+	l := GetParallelLimits()
+
 	// Limite de workers = nb CPU (max 8 pour ne pas saturer la machine)
-	workers := runtime.NumCPU()
-	if workers > 8 {
-		workers = 8
+	workers := l.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+		if workers > 8 {
+			workers = 8
+		}
 	}
 
 	results := make([][]byte, len(items))
@@ -26,7 +77,11 @@ func ParallelConvert[T any](items []T, fn func(int, T) ([]byte, error)) ([][]byt
 		go func() {
 			defer wg.Done()
 			for i := range tasks {
+				// Admission selon la mémoire estimée de l'élément
+				c := cost(items[i])
+				budget.acquire(c)
 				res, err := fn(i, items[i])
+				budget.release(c)
 
 				mu.Lock()
 				if err != nil && firstError == nil {
@@ -53,3 +108,114 @@ func ParallelConvert[T any](items []T, fn func(int, T) ([]byte, error)) ([][]byt
 	}
 	return results, nil
 }
+
+// estimateMemory estime la mémoire nécessaire au traitement d'un élément : pour un chemin
+// d'image, voir decodedMemory. 0 si l'estimation est impossible.
+func estimateMemory(item any) int64 {
+	path, ok := item.(string)
+	if !ok {
+		return 0
+	}
+	cfg, ok := readConfig(path)
+	if !ok {
+		return 0
+	}
+	return decodedMemory(cfg)
+}
+
+// decodedMemory estime la mémoire d'une image décodée d'après son en-tête, doublée pour les
+// copies intermédiaires (conversion de couleurs, transformations).
+func decodedMemory(cfg image.Config) int64 {
+	return int64(cfg.Width) * int64(cfg.Height) * bytesPerPixel(cfg) * 2
+}
+
+// ConversionCost retourne l'estimation mémoire d'une conversion de fichier avec opts, à passer
+// à ParallelConvertWithCost : image décodée et copies, plus l'encodage dans le format demandé.
+func ConversionCost(opts *Options) func(string) int64 {
+	format := ""
+	if opts != nil {
+		format = strings.ToUpper(opts.Format)
+	}
+	return func(path string) int64 {
+		cfg, ok := readConfig(path)
+		if !ok {
+			return 0
+		}
+		return decodedMemory(cfg) + encodeMemory(cfg.Width, cfg.Height, format, opts)
+	}
+}
+
+// encodeMemory estime la mémoire d'un encodage de w×h pixels : une copie RGBA (recadrage,
+// profondeur) et la mémoire de travail de l'encodeur JPEG interne s'il est utilisé.
+func encodeMemory(w, h int, format string, opts *Options) int64 {
+	bpp := int64(4)
+	if (format == "JPEG" || format == "JPG") && usesCustomJPEG(opts) {
+		bpp += jpegEncoderBytesPerPixel
+	}
+	return int64(w) * int64(h) * bpp
+}
+
+// readConfig lit les dimensions et le modèle de couleur dans l'en-tête de l'image.
+func readConfig(path string) (image.Config, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return image.Config{}, false
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	return cfg, err == nil
+}
+
+// bytesPerPixel donne la taille d'un pixel une fois décodé selon le modèle de couleur.
+func bytesPerPixel(cfg image.Config) int64 {
+	switch cfg.ColorModel {
+	case color.GrayModel:
+		return 1
+	case color.Gray16Model:
+		return 2
+	case color.RGBA64Model, color.NRGBA64Model:
+		return 8
+	}
+	return 4
+}
+
+// memoryBudget admet les éléments dans l'ordre d'arrivée tant que la mémoire estimée en
+// cours reste sous la limite courante. Un élément plus gros que le budget passe seul.
+type memoryBudget struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	inUse   int64
+	next    int // prochain ticket distribué
+	serving int // ticket admis en premier, pour ne pas affamer les grosses images
+}
+
+func newMemoryBudget() *memoryBudget {
+	b := &memoryBudget{}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+func (b *memoryBudget) acquire(cost int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ticket := b.next
+	b.next++
+	for {
+		limit := GetParallelLimits().MemoryBudget
+		if ticket == b.serving && (limit <= 0 || b.inUse == 0 || b.inUse+cost <= limit) {
+			break
+		}
+		b.cond.Wait()
+	}
+	b.inUse += cost
+	b.serving++
+	b.cond.Broadcast()
+}
+
+func (b *memoryBudget) release(cost int64) {
+	b.mu.Lock()
+	b.inUse -= cost
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
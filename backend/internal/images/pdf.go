@@ -0,0 +1,186 @@
+package images
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Écriture PDF minimale : des pages contenant des images JPEG (DCTDecode) placées
+// librement. Suffisant pour les planches contact et l'assemblage d'images en PDF.
+
+// pdfImage est une image JPEG prête à être intégrée telle quelle
+type pdfImage struct {
+	data   []byte
+	width  int
+	height int
+	gray   bool
+}
+
+// pdfPlacement positionne une image sur la page (en points, origine en bas à gauche)
+type pdfPlacement struct {
+	image      *pdfImage
+	x, y, w, h float64
+}
+
+// pdfPage décrit une page et son contenu
+type pdfPage struct {
+	width, height float64 // en points (1/72 de pouce)
+	images        []pdfPlacement
+}
+
+// newPDFImage prépare une image JPEG pour le PDF en lisant ses dimensions et composantes.
+func newPDFImage(jpegData []byte) (*pdfImage, error) {
+	w, h, comps, err := jpegInfo(jpegData)
+	if err != nil {
+		return nil, err
+	}
+	if comps != 1 && comps != 3 {
+		return nil, fmt.Errorf("JPEG à %d composantes non supporté dans le PDF", comps)
+	}
+	return &pdfImage{data: jpegData, width: w, height: h, gray: comps == 1}, nil
+}
+
+// jpegInfo lit la taille et le nombre de composantes dans le segment SOF.
+func jpegInfo(data []byte) (int, int, int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 0, 0, 0, errors.New("JPEG invalide")
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			break
+		}
+		marker := data[i+1]
+		n := int(data[i+2])<<8 | int(data[i+3])
+		if marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC {
+			if i+10 > len(data) {
+				break
+			}
+			h := int(data[i+5])<<8 | int(data[i+6])
+			w := int(data[i+7])<<8 | int(data[i+8])
+			return w, h, int(data[i+9]), nil
+		}
+		i += 2 + n
+	}
+	return 0, 0, 0, errors.New("segment SOF introuvable dans le JPEG")
+}
+
+// writePDF écrit un document composé des pages données.
+func writePDF(w io.Writer, pages []pdfPage) error {
+	if len(pages) == 0 {
+		return errors.New("aucune page à écrire")
+	}
+
+	bw := bufio.NewWriter(w)
+	pw := &pdfWriter{w: bw}
+	pw.printf("%%PDF-1.4\n%%\xE2\xE3\xCF\xD3\n")
+
+	// Numérotation : 1 = catalogue, 2 = arbre des pages, puis page, contenu et images
+	imageIDs := map[*pdfImage]int{}
+	next := 3
+	pageIDs := make([]int, len(pages))
+	contentIDs := make([]int, len(pages))
+	for i, p := range pages {
+		pageIDs[i], contentIDs[i] = next, next+1
+		next += 2
+		for _, pl := range p.images {
+			if _, ok := imageIDs[pl.image]; !ok {
+				imageIDs[pl.image] = next
+				next++
+			}
+		}
+	}
+	pw.offsets = make([]int, next)
+
+	pw.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+
+	var kids bytes.Buffer
+	for _, id := range pageIDs {
+		fmt.Fprintf(&kids, "%d 0 R ", id)
+	}
+	pw.object(2, fmt.Sprintf("<< /Type /Pages /Kids [ %s] /Count %d >>", kids.String(), len(pages)))
+
+	written := map[*pdfImage]bool{}
+	for i, p := range pages {
+		var resources, content bytes.Buffer
+		names := map[*pdfImage]string{}
+		for _, pl := range p.images {
+			if _, ok := names[pl.image]; ok {
+				continue
+			}
+			name := fmt.Sprintf("Im%d", len(names))
+			names[pl.image] = name
+			fmt.Fprintf(&resources, "/%s %d 0 R ", name, imageIDs[pl.image])
+		}
+		for _, pl := range p.images {
+			fmt.Fprintf(&content, "q %.2f 0 0 %.2f %.2f %.2f cm /%s Do Q\n", pl.w, pl.h, pl.x, pl.y, names[pl.image])
+		}
+
+		pw.object(pageIDs[i], fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /XObject << %s>> >> /Contents %d 0 R >>",
+			p.width, p.height, resources.String(), contentIDs[i]))
+		pw.stream(contentIDs[i], "", content.Bytes())
+
+		for _, pl := range p.images {
+			if written[pl.image] {
+				continue
+			}
+			written[pl.image] = true
+			space := "/DeviceRGB"
+			if pl.image.gray {
+				space = "/DeviceGray"
+			}
+			dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /DCTDecode ",
+				pl.image.width, pl.image.height, space)
+			pw.stream(imageIDs[pl.image], dict, pl.image.data)
+		}
+	}
+
+	// Table des références croisées
+	xref := pw.n
+	pw.printf("xref\n0 %d\n0000000000 65535 f \n", next)
+	for id := 1; id < next; id++ {
+		pw.printf("%010d 00000 n \n", pw.offsets[id])
+	}
+	pw.printf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", next, xref)
+
+	if pw.err != nil {
+		return pw.err
+	}
+	return bw.Flush()
+}
+
+// pdfWriter garde la trace des positions des objets pour la table xref
+type pdfWriter struct {
+	w       io.Writer
+	n       int
+	err     error
+	offsets []int
+}
+
+func (p *pdfWriter) write(b []byte) {
+	if p.err != nil {
+		return
+	}
+	var n int
+	n, p.err = p.w.Write(b)
+	p.n += n
+}
+
+func (p *pdfWriter) printf(format string, args ...any) {
+	p.write([]byte(fmt.Sprintf(format, args...)))
+}
+
+func (p *pdfWriter) object(id int, body string) {
+	p.offsets[id] = p.n
+	p.printf("%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+func (p *pdfWriter) stream(id int, dict string, data []byte) {
+	p.offsets[id] = p.n
+	p.printf("%d 0 obj\n<< %s/Length %d >>\nstream\n", id, dict, len(data))
+	p.write(data)
+	p.printf("\nendstream\nendobj\n")
+}
@@ -0,0 +1,287 @@
+package images
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"math"
+	"os"
+)
+
+// Placeholders regroupe les aperçus légers affichés pendant le chargement d'une image
+type Placeholders struct {
+	BlurHash  string `json:"blurhash"`
+	ThumbHash string `json:"thumbhash"` // octets encodés en base64
+	LQIP      string `json:"lqip"`      // mini JPEG en data URI
+}
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// ComputePlaceholders calcule BlurHash, ThumbHash et LQIP d'une image décodée.
+func ComputePlaceholders(img image.Image) (*Placeholders, error) {
+	lqip, err := lqipDataURI(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Placeholders{
+		BlurHash:  blurHash(img),
+		ThumbHash: base64.StdEncoding.EncodeToString(thumbHash(img)),
+		LQIP:      lqip,
+	}, nil
+}
+
+// PlaceholdersFromFile décode un fichier (orientation EXIF appliquée) puis calcule ses placeholders.
+func PlaceholdersFromFile(path string) (*Placeholders, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("échec lecture '%s' : %w", path, err)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("erreur de décodage de l'image : %w", err)
+	}
+	return ComputePlaceholders(applyOrientation(img, exifOrientation(src)))
+}
+
+// fitWithin réduit img pour tenir dans limit×limit (sans agrandissement) et renvoie des pixels NRGBA.
+func fitWithin(img image.Image, limit int) *image.NRGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > limit || h > limit {
+		if w >= h {
+			h = int(math.Max(1, math.Round(float64(h)*float64(limit)/float64(w))))
+			w = limit
+		} else {
+			w = int(math.Max(1, math.Round(float64(w)*float64(limit)/float64(h))))
+			h = limit
+		}
+		img = resize(img, w, h)
+		b = img.Bounds()
+	}
+	if n, ok := img.(*image.NRGBA); ok && n.Rect.Min == (image.Point{}) {
+		return n
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+// blurHash encode l'image selon l'algorithme BlurHash (4×3 composantes, 3×4 en portrait).
+func blurHash(img image.Image) string {
+	px := fitWithin(img, 64)
+	w, h := px.Rect.Dx(), px.Rect.Dy()
+	nx, ny := 4, 3
+	if h > w {
+		nx, ny = 3, 4
+	}
+
+	// Conversion en linéaire une seule fois
+	lin := make([][3]float64, w*h)
+	for i := range lin {
+		o := i * 4
+		lin[i] = [3]float64{
+			srgbToLinear(float64(px.Pix[o]) / 255),
+			srgbToLinear(float64(px.Pix[o+1]) / 255),
+			srgbToLinear(float64(px.Pix[o+2]) / 255),
+		}
+	}
+
+	factors := make([][3]float64, 0, nx*ny)
+	for j := 0; j < ny; j++ {
+		for i := 0; i < nx; i++ {
+			norm := 2.0
+			if i == 0 && j == 0 {
+				norm = 1
+			}
+			var f [3]float64
+			for y := 0; y < h; y++ {
+				cy := math.Cos(math.Pi * float64(j) * float64(y) / float64(h))
+				for x := 0; x < w; x++ {
+					basis := norm * math.Cos(math.Pi*float64(i)*float64(x)/float64(w)) * cy
+					p := lin[y*w+x]
+					f[0] += basis * p[0]
+					f[1] += basis * p[1]
+					f[2] += basis * p[2]
+				}
+			}
+			scale := 1 / float64(w*h)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var sb []byte
+	sb = appendBase83(sb, (nx-1)+(ny-1)*9, 1)
+
+	maxValue := 1.0
+	if len(factors) > 1 {
+		actual := 0.0
+		for _, f := range factors[1:] {
+			actual = math.Max(actual, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantised := int(math.Max(0, math.Min(82, math.Floor(actual*166-0.5))))
+		maxValue = float64(quantised+1) / 166
+		sb = appendBase83(sb, quantised, 1)
+	} else {
+		sb = appendBase83(sb, 0, 1)
+	}
+
+	dc := factors[0]
+	toSRGB8 := func(v float64) int { return int(math.Round(linearToSRGB(v) * 255)) }
+	sb = appendBase83(sb, toSRGB8(dc[0])<<16|toSRGB8(dc[1])<<8|toSRGB8(dc[2]), 4)
+
+	quant := func(v float64) int {
+		s := math.Copysign(math.Pow(math.Abs(v/maxValue), 0.5), v)
+		return int(math.Max(0, math.Min(18, math.Floor(s*9+9.5))))
+	}
+	for _, f := range factors[1:] {
+		sb = appendBase83(sb, quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2)
+	}
+	return string(sb)
+}
+
+func appendBase83(dst []byte, value, length int) []byte {
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		dst = append(dst, base83Chars[digit])
+	}
+	return dst
+}
+
+// thumbHash encode l'image selon l'algorithme ThumbHash (image réduite à 100×100 maximum).
+func thumbHash(img image.Image) []byte {
+	px := fitWithin(img, 100)
+	w, h := px.Rect.Dx(), px.Rect.Dy()
+	n := w * h
+	round := func(v float64) int { return int(math.Floor(v + 0.5)) }
+
+	// Couleur moyenne pondérée par l'alpha
+	var avgR, avgG, avgB, avgA float64
+	for i := 0; i < n; i++ {
+		o := i * 4
+		alpha := float64(px.Pix[o+3]) / 255
+		avgR += alpha / 255 * float64(px.Pix[o])
+		avgG += alpha / 255 * float64(px.Pix[o+1])
+		avgB += alpha / 255 * float64(px.Pix[o+2])
+		avgA += alpha
+	}
+	if avgA > 0 {
+		avgR /= avgA
+		avgG /= avgA
+		avgB /= avgA
+	}
+
+	hasAlpha := avgA < float64(n)
+	lLimit := 7.0
+	if hasAlpha {
+		lLimit = 5 // moins de bits de luminance quand il y a de l'alpha
+	}
+	maxWH := float64(max(w, h))
+	lx := max(1, round(lLimit*float64(w)/maxWH))
+	ly := max(1, round(lLimit*float64(h)/maxWH))
+
+	// Passage en LPQA, composé sur la couleur moyenne
+	l := make([]float64, n)
+	p := make([]float64, n)
+	q := make([]float64, n)
+	a := make([]float64, n)
+	for i := 0; i < n; i++ {
+		o := i * 4
+		alpha := float64(px.Pix[o+3]) / 255
+		r := avgR*(1-alpha) + alpha/255*float64(px.Pix[o])
+		g := avgG*(1-alpha) + alpha/255*float64(px.Pix[o+1])
+		b := avgB*(1-alpha) + alpha/255*float64(px.Pix[o+2])
+		l[i] = (r + g + b) / 3
+		p[i] = (r+g)/2 - b
+		q[i] = r - g
+		a[i] = alpha
+	}
+
+	encodeChannel := func(channel []float64, nx, ny int) (float64, []float64, float64) {
+		var dc, scale float64
+		var ac []float64
+		fx := make([]float64, w)
+		for cy := 0; cy < ny; cy++ {
+			for cx := 0; cx*ny < nx*(ny-cy); cx++ {
+				for x := 0; x < w; x++ {
+					fx[x] = math.Cos(math.Pi / float64(w) * float64(cx) * (float64(x) + 0.5))
+				}
+				f := 0.0
+				for y := 0; y < h; y++ {
+					fy := math.Cos(math.Pi / float64(h) * float64(cy) * (float64(y) + 0.5))
+					for x := 0; x < w; x++ {
+						f += channel[x+y*w] * fx[x] * fy
+					}
+				}
+				f /= float64(n)
+				if cx > 0 || cy > 0 {
+					ac = append(ac, f)
+					scale = math.Max(scale, math.Abs(f))
+				} else {
+					dc = f
+				}
+			}
+		}
+		if scale > 0 {
+			for i := range ac {
+				ac[i] = 0.5 + 0.5/scale*ac[i]
+			}
+		}
+		return dc, ac, scale
+	}
+
+	lDC, lAC, lScale := encodeChannel(l, max(3, lx), max(3, ly))
+	pDC, pAC, pScale := encodeChannel(p, 3, 3)
+	qDC, qAC, qScale := encodeChannel(q, 3, 3)
+	var aDC, aScale float64
+	var aAC []float64
+	if hasAlpha {
+		aDC, aAC, aScale = encodeChannel(a, 5, 5)
+	}
+
+	isLandscape := w > h
+	header24 := round(63*lDC) | round(31.5+31.5*pDC)<<6 | round(31.5+31.5*qDC)<<12 | round(31*lScale)<<18
+	if hasAlpha {
+		header24 |= 1 << 23
+	}
+	header16 := round(63*pScale)<<3 | round(63*qScale)<<9
+	if isLandscape {
+		header16 |= ly | 1<<15
+	} else {
+		header16 |= lx
+	}
+
+	hash := []byte{byte(header24), byte(header24 >> 8), byte(header24 >> 16), byte(header16), byte(header16 >> 8)}
+	channels := [][]float64{lAC, pAC, qAC}
+	if hasAlpha {
+		hash = append(hash, byte(round(15*aDC)|round(15*aScale)<<4))
+		channels = append(channels, aAC)
+	}
+
+	acStart := len(hash)
+	acIndex := 0
+	for _, ac := range channels {
+		for _, f := range ac {
+			pos := acStart + acIndex>>1
+			for len(hash) <= pos {
+				hash = append(hash, 0)
+			}
+			hash[pos] |= byte(round(15*f) << ((acIndex & 1) << 2))
+			acIndex++
+		}
+	}
+	return hash
+}
+
+// lqipDataURI produit une miniature JPEG de 16 px maximum sous forme de data URI.
+func lqipDataURI(img image.Image) (string, error) {
+	small := fitWithin(img, 16)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, small, &jpeg.Options{Quality: 50}); err != nil {
+		return "", fmt.Errorf("échec de l'encodage LQIP : %w", err)
+	}
+	return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
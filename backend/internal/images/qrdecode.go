@@ -0,0 +1,1037 @@
+package images
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+// Corrections d'erreur QR par version (index 0 = version 1), niveaux dans l'ordre L, M, Q, H :
+// codes de correction par bloc et nombre de blocs
+var (
+	qrECCPerBlock = [4][40]int{
+		{7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+		{10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+		{13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+		{17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	}
+	qrBlocks = [4][40]int{
+		{1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+		{1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+		{1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+		{1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+	}
+)
+
+// finderPattern est un motif de repérage (carré 7×7 d'un coin) candidat
+type finderPattern struct {
+	x, y   float64 // centre en pixels
+	module float64 // taille estimée d'un module
+	count  int     // nombre de lignes où il a été détecté
+}
+
+// findQRCodes localise les motifs de repérage, puis tente de décoder chaque triplet
+// plausible. Plusieurs QR codes peuvent être lus dans la même image.
+func findQRCodes(bm *bitMatrix) []ScannedCode {
+	candidates := findFinderPatterns(bm)
+	if len(candidates) < 3 {
+		return nil
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].count > candidates[j].count })
+	if len(candidates) > 12 {
+		candidates = candidates[:12]
+	}
+
+	type triple struct {
+		p     [3]int
+		score float64
+	}
+	var triples []triple
+	for a := 0; a < len(candidates); a++ {
+		for b := a + 1; b < len(candidates); b++ {
+			for c := b + 1; c < len(candidates); c++ {
+				if s, ok := tripleScore(candidates[a], candidates[b], candidates[c]); ok {
+					triples = append(triples, triple{[3]int{a, b, c}, s})
+				}
+			}
+		}
+	}
+	sort.Slice(triples, func(i, j int) bool { return triples[i].score < triples[j].score })
+
+	codes := []ScannedCode{}
+	used := make([]bool, len(candidates))
+	for n, t := range triples {
+		if n >= 30 {
+			break
+		}
+		if used[t.p[0]] || used[t.p[1]] || used[t.p[2]] {
+			continue
+		}
+		code, err := decodeQRAt(bm, candidates[t.p[0]], candidates[t.p[1]], candidates[t.p[2]])
+		if err != nil {
+			continue
+		}
+		used[t.p[0]], used[t.p[1]], used[t.p[2]] = true, true, true
+		codes = append(codes, *code)
+	}
+	return codes
+}
+
+// findFinderPatterns cherche ligne par ligne la séquence sombre/clair/sombre/clair/sombre
+// de proportions 1:1:3:1:1, confirmée à la verticale puis à l'horizontale.
+func findFinderPatterns(bm *bitMatrix) []*finderPattern {
+	var found []*finderPattern
+	step := max(1, bm.h/800)
+	for y := 0; y < bm.h; y += step {
+		runs := bm.rowRuns(y)
+		x := runs[0]
+		for i := 1; i+4 < len(runs); i += 2 {
+			if finderRatio(runs[i : i+5]) {
+				total := runsSum(runs[i : i+5])
+				cx := float64(x+runs[i]+runs[i+1]) + float64(runs[i+2])/2
+				if p := confirmFinder(bm, cx, float64(y)+0.5, total); p != nil {
+					addFinder(&found, p)
+				}
+			}
+			x += runs[i] + runs[i+1]
+		}
+	}
+	return found
+}
+
+// finderRatio vérifie les proportions 1:1:3:1:1 à une demi-module près.
+func finderRatio(runs []int) bool {
+	total := runsSum(runs)
+	if total < 7 {
+		return false
+	}
+	unit := float64(total) / 7
+	for i, n := range runs {
+		want := unit
+		if i == 2 {
+			want = 3 * unit
+		}
+		if math.Abs(float64(n)-want) >= want/2 {
+			return false
+		}
+	}
+	return true
+}
+
+// confirmFinder recoupe un candidat à la verticale puis à l'horizontale et recentre le motif.
+func confirmFinder(bm *bitMatrix, cx, cy float64, total int) *finderPattern {
+	vRuns, vCenter, ok := crossRuns(bm, cx, cy, 0, 1, total)
+	if !ok || !finderRatio(vRuns[:]) || 5*absInt(runsSum(vRuns[:])-total) >= 2*total {
+		return nil
+	}
+	cy = vCenter
+	hRuns, hCenter, ok := crossRuns(bm, cx, cy, 1, 0, total)
+	if !ok || !finderRatio(hRuns[:]) || 5*absInt(runsSum(hRuns[:])-total) >= 2*total {
+		return nil
+	}
+	return &finderPattern{
+		x:      hCenter,
+		y:      cy,
+		module: float64(runsSum(vRuns[:])+runsSum(hRuns[:])) / 14,
+		count:  1,
+	}
+}
+
+// crossRuns mesure les cinq plages du motif centré en (cx, cy) selon la direction (dx, dy)
+// et retourne la nouvelle coordonnée du centre sur cet axe.
+func crossRuns(bm *bitMatrix, cx, cy float64, dx, dy int, total int) ([5]int, float64, bool) {
+	var runs [5]int
+	x, y := int(cx), int(cy)
+	if !bm.get(x, y) {
+		return runs, 0, false
+	}
+	limit := total // une plage extérieure ne peut dépasser la taille totale du motif
+
+	// Vers l'avant : centre, clair, sombre
+	fwd := 0
+	for bm.get(x+dx*fwd, y+dy*fwd) {
+		fwd++
+	}
+	k := fwd
+	for k < limit*2 && inside(bm, x+dx*k, y+dy*k) && !bm.get(x+dx*k, y+dy*k) {
+		runs[3]++
+		k++
+	}
+	for k < limit*2 && bm.get(x+dx*k, y+dy*k) {
+		runs[4]++
+		k++
+	}
+
+	// Vers l'arrière
+	back := 1
+	for bm.get(x-dx*back, y-dy*back) {
+		back++
+	}
+	k = back
+	for k < limit*2 && inside(bm, x-dx*k, y-dy*k) && !bm.get(x-dx*k, y-dy*k) {
+		runs[1]++
+		k++
+	}
+	for k < limit*2 && bm.get(x-dx*k, y-dy*k) {
+		runs[0]++
+		k++
+	}
+	runs[2] = fwd + back - 1
+	if runs[0] == 0 || runs[1] == 0 || runs[3] == 0 || runs[4] == 0 {
+		return runs, 0, false
+	}
+
+	start := x - (back - 1)
+	if dy != 0 {
+		start = y - (back - 1)
+	}
+	return runs, float64(start) + float64(runs[2])/2, true
+}
+
+func inside(bm *bitMatrix, x, y int) bool {
+	return x >= 0 && y >= 0 && x < bm.w && y < bm.h
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// addFinder fusionne p avec un candidat existant au même endroit et de même taille.
+func addFinder(found *[]*finderPattern, p *finderPattern) {
+	for _, f := range *found {
+		if math.Abs(f.x-p.x) <= f.module && math.Abs(f.y-p.y) <= f.module && math.Abs(f.module-p.module) <= math.Max(1, f.module/2) {
+			n := float64(f.count)
+			f.x = (f.x*n + p.x) / (n + 1)
+			f.y = (f.y*n + p.y) / (n + 1)
+			f.module = (f.module*n + p.module) / (n + 1)
+			f.count++
+			return
+		}
+	}
+	*found = append(*found, p)
+}
+
+// tripleScore évalue si trois motifs forment les coins d'un QR code : triangle rectangle
+// isocèle et modules de même taille. Plus le score est bas, plus le triplet est plausible.
+func tripleScore(a, b, c *finderPattern) (float64, bool) {
+	minM := math.Min(a.module, math.Min(b.module, c.module))
+	maxM := math.Max(a.module, math.Max(b.module, c.module))
+	if maxM > 1.5*minM {
+		return 0, false
+	}
+	sides := [][2]float64{{a.x - b.x, a.y - b.y}, {a.x - c.x, a.y - c.y}, {b.x - c.x, b.y - c.y}}
+	sort.Slice(sides, func(i, j int) bool {
+		return math.Hypot(sides[i][0], sides[i][1]) < math.Hypot(sides[j][0], sides[j][1])
+	})
+	d := make([]float64, 3)
+	for i, v := range sides {
+		d[i] = math.Hypot(v[0], v[1])
+	}
+	if d[0]/d[1] < 0.66 {
+		return 0, false
+	}
+	right := d[2]*d[2]/(d[0]*d[0]+d[1]*d[1]) - 1
+	if math.Abs(right) > 0.3 {
+		return 0, false
+	}
+	// Les modules des motifs sont mesurés sur des plages horizontales et verticales, plus
+	// longues quand le code est tourné : on les ramène à l'axe du côté le plus court
+	axis := math.Max(math.Abs(sides[0][0]), math.Abs(sides[0][1])) / d[0]
+	modules := d[0] / ((minM + maxM) / 2 * axis)
+	if modules < 10 || modules > 180 {
+		return 0, false
+	}
+	return math.Abs(right) + (1 - d[0]/d[1]) + (maxM/minM - 1), true
+}
+
+// decodeQRAt échantillonne et décode le QR code repéré par trois motifs.
+func decodeQRAt(bm *bitMatrix, a, b, c *finderPattern) (*ScannedCode, error) {
+	// Le coin haut-gauche est opposé au plus grand côté
+	tl, p1, p2 := a, b, c
+	ab, ac, bc := math.Hypot(a.x-b.x, a.y-b.y), math.Hypot(a.x-c.x, a.y-c.y), math.Hypot(b.x-c.x, b.y-c.y)
+	if ab >= bc && ab >= ac {
+		tl, p1, p2 = c, a, b
+	} else if ac >= bc && ac >= ab {
+		tl, p1, p2 = b, a, c
+	}
+	// Sens horaire dans l'image : haut-gauche → haut-droit → bas-gauche
+	tr, bl := p1, p2
+	if (p1.x-tl.x)*(p2.y-tl.y)-(p1.y-tl.y)*(p2.x-tl.x) < 0 {
+		tr, bl = p2, p1
+	}
+
+	module := qrModuleSize(bm, tl, tr, bl)
+	across := (math.Hypot(tr.x-tl.x, tr.y-tl.y) + math.Hypot(bl.x-tl.x, bl.y-tl.y)) / (2 * module)
+	dim := int(math.Round(across)) + 7
+	switch dim % 4 {
+	case 0:
+		dim++
+	case 2:
+		dim--
+	case 3:
+		dim += 2
+	}
+
+	tried := map[int]bool{}
+	for _, d := range []int{dim, dim - 4, dim + 4} {
+		if d < 21 || d > 177 || tried[d] {
+			continue
+		}
+		tried[d] = true
+		h := qrTransform(bm, tl, tr, bl, d, module)
+		if h == nil {
+			continue
+		}
+		grid := sampleQRGrid(bm, h, d)
+		// Les versions 7 et plus indiquent leur taille : on corrige l'estimation si besoin
+		if v, ok := readQRVersion(grid); ok && 17+4*v != d && !tried[17+4*v] {
+			d = 17 + 4*v
+			tried[d] = true
+			if h = qrTransform(bm, tl, tr, bl, d, module); h == nil {
+				continue
+			}
+			grid = sampleQRGrid(bm, h, d)
+		}
+
+		content, err := decodeQRGrid(grid)
+		if err != nil {
+			content, err = decodeQRGrid(transposeGrid(grid)) // image en miroir
+		}
+		if err != nil {
+			continue
+		}
+		fd := float64(d)
+		points := make([]CodePoint, 0, 4)
+		for _, corner := range [][2]float64{{0, 0}, {fd, 0}, {fd, fd}, {0, fd}} {
+			x, y := h.apply(corner[0], corner[1])
+			points = append(points, CodePoint{int(math.Round(x)), int(math.Round(y))})
+		}
+		return &ScannedCode{Type: CodeQR, Content: content, Points: points}, nil
+	}
+	return nil, errNoCode
+}
+
+// qrModuleSize mesure la taille d'un module le long des lignes entre motifs, ce qui reste
+// juste quand le code est tourné (les plages horizontales s'allongent alors).
+func qrModuleSize(bm *bitMatrix, tl, tr, bl *finderPattern) float64 {
+	var sum float64
+	n := 0
+	for _, pair := range [][2]*finderPattern{{tl, tr}, {tr, tl}, {tl, bl}, {bl, tl}} {
+		if m, ok := moduleAlong(bm, pair[0], pair[1]); ok {
+			sum += m
+			n++
+		}
+	}
+	if n == 0 {
+		return (tl.module + tr.module + bl.module) / 3
+	}
+	return sum / float64(n)
+}
+
+// moduleAlong part du centre de from vers to et mesure la distance jusqu'au bord extérieur
+// du motif (centre sombre, anneau clair, anneau sombre), soit 3,5 modules.
+func moduleAlong(bm *bitMatrix, from, to *finderPattern) (float64, bool) {
+	dist := math.Hypot(to.x-from.x, to.y-from.y)
+	if dist == 0 {
+		return 0, false
+	}
+	dx, dy := (to.x-from.x)/dist, (to.y-from.y)/dist
+	state := 0 // 0 centre sombre, 1 anneau clair, 2 anneau sombre
+	for t := 0.0; t < dist/2; t++ {
+		dark := bm.get(int(math.Floor(from.x+dx*t)), int(math.Floor(from.y+dy*t)))
+		if dark == (state%2 == 1) {
+			state++
+			if state == 3 {
+				m := t / 3.5
+				return m, math.Abs(m-from.module) < from.module
+			}
+		}
+	}
+	return 0, false
+}
+
+// homography projette les coordonnées en modules vers les pixels de l'image
+type homography [8]float64
+
+func (h *homography) apply(u, v float64) (float64, float64) {
+	w := h[6]*u + h[7]*v + 1
+	return (h[0]*u + h[1]*v + h[2]) / w, (h[3]*u + h[4]*v + h[5]) / w
+}
+
+// qrTransform calcule la projection du QR code de côté dim. Le quatrième point est le
+// motif d'alignement (version 2 et plus) s'il est retrouvé, sinon le coin déduit des trois autres.
+func qrTransform(bm *bitMatrix, tl, tr, bl *finderPattern, dim int, module float64) *homography {
+	fd := float64(dim)
+	brX, brY := tr.x-tl.x+bl.x, tr.y-tl.y+bl.y
+	src := [][2]float64{{3.5, 3.5}, {fd - 3.5, 3.5}, {3.5, fd - 3.5}, {fd - 3.5, fd - 3.5}}
+	dst := [][2]float64{{tl.x, tl.y}, {tr.x, tr.y}, {bl.x, bl.y}, {brX, brY}}
+
+	if dim >= 25 {
+		// Position estimée du motif d'alignement, 3 modules avant le coin bas-droit
+		f := 1 - 3/(fd-7)
+		ex, ey := tl.x+f*(brX-tl.x), tl.y+f*(brY-tl.y)
+		src[3] = [2]float64{fd - 6.5, fd - 6.5}
+		dst[3] = [2]float64{ex, ey}
+		for _, radius := range []float64{4, 8, 16} {
+			if x, y, ok := findAlignment(bm, ex, ey, module, radius*module); ok {
+				dst[3] = [2]float64{x, y}
+				break
+			}
+		}
+	}
+	return solveHomography(src, dst)
+}
+
+// findAlignment cherche autour de (ex, ey) un module sombre isolé entouré d'un anneau clair :
+// le centre du motif d'alignement 5×5.
+func findAlignment(bm *bitMatrix, ex, ey, module, radius float64) (float64, float64, bool) {
+	x0, x1 := max(0, int(ex-radius)), min(bm.w, int(ex+radius)+1)
+	y0, y1 := max(0, int(ey-radius)), min(bm.h, int(ey+radius)+1)
+	ok1 := func(n int) bool { return math.Abs(float64(n)-module) < module/2+1 }
+
+	bestD := math.Inf(1)
+	var bx, by float64
+	for y := y0; y < y1; y++ {
+		x := x0
+		for x < x1 {
+			// Plage sombre commençant en x
+			if !bm.get(x, y) {
+				x++
+				continue
+			}
+			s := x
+			for x < x1 && bm.get(x, y) {
+				x++
+			}
+			dark := x - s
+			if !ok1(dark) {
+				continue
+			}
+			left, right := 0, 0
+			for inside(bm, s-1-left, y) && !bm.get(s-1-left, y) && left <= int(2*module) {
+				left++
+			}
+			for inside(bm, x+right, y) && !bm.get(x+right, y) && right <= int(2*module) {
+				right++
+			}
+			if !ok1(left) || !ok1(right) {
+				continue
+			}
+			cx := float64(s) + float64(dark)/2
+			runs, cy, ok := alignmentVertical(bm, int(cx), y, module)
+			if !ok || !ok1(runs[0]) || !ok1(runs[1]) || !ok1(runs[2]) {
+				continue
+			}
+			if d := math.Hypot(cx-ex, cy-ey); d < bestD {
+				bestD, bx, by = d, cx, cy
+			}
+		}
+	}
+	return bx, by, !math.IsInf(bestD, 1)
+}
+
+// alignmentVertical mesure à la verticale les plages clair/sombre/clair autour de (x, y).
+func alignmentVertical(bm *bitMatrix, x, y int, module float64) ([3]int, float64, bool) {
+	var runs [3]int
+	limit := int(2*module) + 2
+	up := 0
+	for bm.get(x, y-up-1) {
+		up++
+	}
+	down := 0
+	for bm.get(x, y+down) {
+		down++
+	}
+	runs[1] = up + down
+	for k := y - up - 1; inside(bm, x, k) && !bm.get(x, k) && runs[0] <= limit; k-- {
+		runs[0]++
+	}
+	for k := y + down; inside(bm, x, k) && !bm.get(x, k) && runs[2] <= limit; k++ {
+		runs[2]++
+	}
+	return runs, float64(y-up) + float64(runs[1])/2, runs[1] > 0
+}
+
+// solveHomography résout les 8 coefficients de la projection envoyant src sur dst.
+func solveHomography(src, dst [][2]float64) *homography {
+	var a [8][9]float64
+	for i := 0; i < 4; i++ {
+		u, v := src[i][0], src[i][1]
+		x, y := dst[i][0], dst[i][1]
+		a[2*i] = [9]float64{u, v, 1, 0, 0, 0, -u * x, -v * x, x}
+		a[2*i+1] = [9]float64{0, 0, 0, u, v, 1, -u * y, -v * y, y}
+	}
+	// Élimination de Gauss avec pivot partiel
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for r := col + 1; r < 8; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-9 {
+			return nil
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for r := 0; r < 8; r++ {
+			if r == col {
+				continue
+			}
+			f := a[r][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[r][k] -= f * a[col][k]
+			}
+		}
+	}
+	var h homography
+	for i := range h {
+		h[i] = a[i][8] / a[i][i]
+	}
+	return &h
+}
+
+// sampleQRGrid lit la couleur du centre de chaque module.
+func sampleQRGrid(bm *bitMatrix, h *homography, dim int) [][]bool {
+	grid := make([][]bool, dim)
+	for r := range grid {
+		grid[r] = make([]bool, dim)
+		for c := range grid[r] {
+			x, y := h.apply(float64(c)+0.5, float64(r)+0.5)
+			grid[r][c] = bm.get(int(math.Floor(x)), int(math.Floor(y)))
+		}
+	}
+	return grid
+}
+
+func transposeGrid(grid [][]bool) [][]bool {
+	t := make([][]bool, len(grid))
+	for r := range t {
+		t[r] = make([]bool, len(grid))
+		for c := range t[r] {
+			t[r][c] = grid[c][r]
+		}
+	}
+	return t
+}
+
+// readQRVersion lit le bloc de version (versions 7 et plus) situé près du coin haut-droit.
+func readQRVersion(grid [][]bool) (int, bool) {
+	size := len(grid)
+	if size < 45 {
+		return 0, false
+	}
+	read, readT := 0, 0
+	for i := 0; i < 18; i++ {
+		a, b := size-11+i%3, i/3
+		if grid[b][a] {
+			read |= 1 << i
+		}
+		if grid[a][b] {
+			readT |= 1 << i
+		}
+	}
+	best, bestDist := 0, 4
+	for v := 7; v <= 40; v++ {
+		rem := v
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ (rem>>11)*0x1F25
+		}
+		code := v<<12 | rem
+		if d := min(bits.OnesCount(uint(code^read)), bits.OnesCount(uint(code^readT))); d < bestDist {
+			best, bestDist = v, d
+		}
+	}
+	return best, best > 0
+}
+
+// decodeQRGrid décode une matrice de modules déjà échantillonnée.
+func decodeQRGrid(grid [][]bool) (string, error) {
+	size := len(grid)
+	version := (size - 17) / 4
+	if version < 1 || version > 40 || size != 17+4*version {
+		return "", fmt.Errorf("taille de QR code invalide : %d", size)
+	}
+
+	level, mask, err := readQRFormat(grid)
+	if err != nil {
+		return "", err
+	}
+
+	// Lecture des codewords en zigzag, de droite à gauche par colonnes de deux
+	function := qrFunctionModules(version)
+	raw := make([]byte, qrRawModules(version)/8)
+	i := 0
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = size - 1 - vert
+				}
+				if function[y][x] || i >= len(raw)*8 {
+					continue
+				}
+				if grid[y][x] != qrMask(mask, x, y) {
+					raw[i>>3] |= 0x80 >> (i & 7)
+				}
+				i++
+			}
+		}
+	}
+
+	data, err := qrCorrect(raw, version, level)
+	if err != nil {
+		return "", err
+	}
+	return parseQRData(data, version)
+}
+
+// readQRFormat retourne le niveau de correction (0 = L … 3 = H) et le masque, à partir
+// de la plus lisible des deux copies des informations de format.
+func readQRFormat(grid [][]bool) (int, int, error) {
+	size := len(grid)
+	bit := func(v bool, i int) int {
+		if v {
+			return 1 << i
+		}
+		return 0
+	}
+	a, b := 0, 0
+	for i := 0; i < 6; i++ {
+		a |= bit(grid[i][8], i)
+	}
+	a |= bit(grid[7][8], 6) | bit(grid[8][8], 7) | bit(grid[8][7], 8)
+	for i := 9; i < 15; i++ {
+		a |= bit(grid[8][14-i], i)
+	}
+	for i := 0; i < 8; i++ {
+		b |= bit(grid[8][size-1-i], i)
+	}
+	for i := 8; i < 15; i++ {
+		b |= bit(grid[size-15+i][8], i)
+	}
+
+	best, bestDist := -1, 4
+	for data := 0; data < 32; data++ {
+		rem := data
+		for i := 0; i < 10; i++ {
+			rem = rem<<1 ^ (rem>>9)*0x537
+		}
+		code := (data<<10 | rem) ^ 0x5412
+		if d := min(bits.OnesCount(uint(code^a)), bits.OnesCount(uint(code^b))); d < bestDist {
+			best, bestDist = data, d
+		}
+	}
+	if best < 0 {
+		return 0, 0, fmt.Errorf("informations de format illisibles")
+	}
+	// Niveau codé 01 = L, 00 = M, 11 = Q, 10 = H
+	return [4]int{1, 0, 3, 2}[best>>3], best & 7, nil
+}
+
+// qrFunctionModules marque les modules hors données : repères, séparateurs, format,
+// rythme, alignement et version.
+func qrFunctionModules(version int) [][]bool {
+	size := 17 + 4*version
+	m := make([][]bool, size)
+	for r := range m {
+		m[r] = make([]bool, size)
+	}
+	fill := func(x0, y0, w, h int) {
+		for y := max(0, y0); y < min(size, y0+h); y++ {
+			for x := max(0, x0); x < min(size, x0+w); x++ {
+				m[y][x] = true
+			}
+		}
+	}
+	fill(6, 0, 1, size)
+	fill(0, 6, size, 1)
+	fill(0, 0, 9, 9)
+	fill(size-8, 0, 8, 9)
+	fill(0, size-8, 9, 8)
+
+	align := qrAlignmentPositions(version)
+	last := len(align) - 1
+	for i, ay := range align {
+		for j, ax := range align {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			fill(ax-2, ay-2, 5, 5)
+		}
+	}
+	if version >= 7 {
+		fill(size-11, 0, 3, 6)
+		fill(0, size-11, 6, 3)
+	}
+	return m
+}
+
+// qrAlignmentPositions retourne les coordonnées des centres des motifs d'alignement.
+func qrAlignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	n := version/7 + 2
+	step := 26
+	if version != 32 {
+		step = (version*4 + n*2 + 1) / (n*2 - 2) * 2
+	}
+	size := 17 + 4*version
+	pos := make([]int, n)
+	pos[0] = 6
+	for i := n - 1; i >= 1; i-- {
+		pos[i] = size - 7 - (n-1-i)*step
+	}
+	return pos
+}
+
+// qrRawModules compte les modules de données (codewords et bits de remplissage).
+func qrRawModules(version int) int {
+	n := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		n -= (25*align-10)*align - 55
+		if version >= 7 {
+			n -= 36
+		}
+	}
+	return n
+}
+
+func qrMask(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	}
+	return ((x+y)%2+x*y%3)%2 == 0
+}
+
+// qrCorrect désentrelace les blocs, corrige chacun par Reed-Solomon et concatène les données.
+func qrCorrect(raw []byte, version, level int) ([]byte, error) {
+	numBlocks := qrBlocks[level][version-1]
+	ecc := qrECCPerBlock[level][version-1]
+	shortLen := len(raw) / numBlocks
+	numShort := numBlocks - len(raw)%numBlocks
+	shortData := shortLen - ecc
+
+	// Les blocs courts ont un octet de données de moins, au même rang
+	blocks := make([][]byte, numBlocks)
+	for b := range blocks {
+		blocks[b] = make([]byte, shortLen+1)
+	}
+	k := 0
+	for i := 0; i <= shortLen; i++ {
+		for b := range blocks {
+			if i == shortData && b < numShort {
+				continue
+			}
+			blocks[b][i] = raw[k]
+			k++
+		}
+	}
+
+	var data []byte
+	for b, block := range blocks {
+		if b < numShort {
+			block = append(block[:shortData:shortData], block[shortData+1:]...)
+		}
+		if err := rsCorrect(block, ecc); err != nil {
+			return nil, err
+		}
+		data = append(data, block[:len(block)-ecc]...)
+	}
+	return data, nil
+}
+
+// Tables du corps GF(256) de polynôme 0x11D
+var gfExp, gfLog = func() ([512]byte, [256]int) {
+	var exp [512]byte
+	var log [256]int
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(x)
+		log[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	for i := 255; i < 512; i++ {
+		exp[i] = exp[i-255]
+	}
+	return exp, log
+}()
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(gfLog[a]+255-gfLog[b])%255]
+}
+
+// gfEval évalue un polynôme de coefficients poids faible en premier.
+func gfEval(p []byte, x byte) byte {
+	var y byte
+	for i := len(p) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ p[i]
+	}
+	return y
+}
+
+// rsCorrect corrige le bloc (données puis ecc codewords de correction) sur place :
+// syndromes, Berlekamp-Massey, recherche de Chien et formule de Forney.
+func rsCorrect(block []byte, ecc int) error {
+	n := len(block)
+	synd := make([]byte, ecc)
+	clean := true
+	for i := range synd {
+		// Le premier octet du bloc est le coefficient de plus haut degré
+		var s byte
+		for _, c := range block {
+			s = gfMul(s, gfExp[i]) ^ c
+		}
+		synd[i] = s
+		clean = clean && s == 0
+	}
+	if clean {
+		return nil
+	}
+
+	// Polynôme localisateur d'erreurs
+	lambda, prev := []byte{1}, []byte{1}
+	errs, shift, prevD := 0, 1, byte(1)
+	for r := 0; r < ecc; r++ {
+		d := synd[r]
+		for i := 1; i <= errs && i < len(lambda); i++ {
+			d ^= gfMul(lambda[i], synd[r-i])
+		}
+		if d == 0 {
+			shift++
+			continue
+		}
+		next := append([]byte(nil), lambda...)
+		coef := gfDiv(d, prevD)
+		for i, p := range prev {
+			for len(next) <= i+shift {
+				next = append(next, 0)
+			}
+			next[i+shift] ^= gfMul(coef, p)
+		}
+		if 2*errs <= r {
+			prev, errs, prevD, shift = lambda, r+1-errs, d, 1
+		} else {
+			shift++
+		}
+		lambda = next
+	}
+	if 2*errs > ecc {
+		return fmt.Errorf("trop d'erreurs dans un bloc")
+	}
+
+	// Positions : racines de lambda aux inverses des localisateurs
+	var positions []int
+	for j := 0; j < n; j++ {
+		if gfEval(lambda, gfExp[(255-(n-1-j))%255]) == 0 {
+			positions = append(positions, j)
+		}
+	}
+	if len(positions) != errs {
+		return fmt.Errorf("erreurs non localisables")
+	}
+
+	// Omega = S × lambda mod x^ecc, dérivée formelle de lambda
+	omega := make([]byte, ecc)
+	for i := range omega {
+		for j := 0; j <= i && j < len(lambda); j++ {
+			omega[i] ^= gfMul(lambda[j], synd[i-j])
+		}
+	}
+	deriv := make([]byte, len(lambda))
+	for i := 1; i < len(lambda); i += 2 {
+		deriv[i-1] = lambda[i]
+	}
+	for _, j := range positions {
+		x := gfExp[(n-1-j)%255]
+		xInv := gfExp[(255-(n-1-j))%255]
+		den := gfEval(deriv, xInv)
+		if den == 0 {
+			return fmt.Errorf("erreurs non corrigibles")
+		}
+		block[j] ^= gfMul(x, gfDiv(gfEval(omega, xInv), den))
+	}
+	return nil
+}
+
+// parseQRData lit les segments (numérique, alphanumérique, octets, kanji) du flux de données.
+func parseQRData(data []byte, version int) (string, error) {
+	pos := 0
+	read := func(n int) (int, bool) {
+		if pos+n > len(data)*8 {
+			return 0, false
+		}
+		v := 0
+		for i := 0; i < n; i++ {
+			v = v<<1 | int(data[(pos+i)>>3]>>(7-(pos+i)&7)&1)
+		}
+		pos += n
+		return v, true
+	}
+	sizeClass := 0
+	if version >= 27 {
+		sizeClass = 2
+	} else if version >= 10 {
+		sizeClass = 1
+	}
+
+	var sb strings.Builder
+	latin1 := false
+	const alnum = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+	invalid := fmt.Errorf("données QR invalides")
+	for {
+		mode, ok := read(4)
+		if !ok || mode == 0 {
+			break
+		}
+		switch mode {
+		case 1: // numérique
+			count, ok := read([3]int{10, 12, 14}[sizeClass])
+			if !ok {
+				return "", invalid
+			}
+			for ; count > 0; count -= 3 {
+				digits := min(count, 3)
+				v, ok := read([4]int{0, 4, 7, 10}[digits])
+				if !ok {
+					return "", invalid
+				}
+				fmt.Fprintf(&sb, "%0*d", digits, v)
+			}
+		case 2: // alphanumérique
+			count, ok := read([3]int{9, 11, 13}[sizeClass])
+			if !ok {
+				return "", invalid
+			}
+			for ; count >= 2; count -= 2 {
+				v, ok := read(11)
+				if !ok || v/45 >= 45 {
+					return "", invalid
+				}
+				sb.WriteByte(alnum[v/45])
+				sb.WriteByte(alnum[v%45])
+			}
+			if count == 1 {
+				v, ok := read(6)
+				if !ok || v >= 45 {
+					return "", invalid
+				}
+				sb.WriteByte(alnum[v])
+			}
+		case 4: // octets, UTF-8 sauf indication ECI ou octets invalides
+			count, ok := read([3]int{8, 16, 16}[sizeClass])
+			if !ok {
+				return "", invalid
+			}
+			buf := make([]byte, count)
+			for i := range buf {
+				v, ok := read(8)
+				if !ok {
+					return "", invalid
+				}
+				buf[i] = byte(v)
+			}
+			if latin1 || !utf8.Valid(buf) {
+				for _, c := range buf {
+					sb.WriteRune(rune(c))
+				}
+			} else {
+				sb.Write(buf)
+			}
+		case 8: // kanji, 13 bits par caractère Shift JIS
+			count, ok := read([3]int{8, 10, 12}[sizeClass])
+			if !ok {
+				return "", invalid
+			}
+			sjis := make([]byte, 0, 2*count)
+			for i := 0; i < count; i++ {
+				v, ok := read(13)
+				if !ok {
+					return "", invalid
+				}
+				c := v/0xC0<<8 | v%0xC0
+				if c < 0x1F00 {
+					c += 0x8140
+				} else {
+					c += 0xC140
+				}
+				sjis = append(sjis, byte(c>>8), byte(c))
+			}
+			text, err := japanese.ShiftJIS.NewDecoder().Bytes(sjis)
+			if err != nil {
+				return "", invalid
+			}
+			sb.Write(text)
+		case 7: // ECI : seuls ISO-8859-1 et UTF-8 changent la lecture des octets
+			v, ok := read(8)
+			if !ok {
+				return "", invalid
+			}
+			switch {
+			case v&0x80 == 0:
+			case v&0xC0 == 0x80:
+				low, _ := read(8)
+				v = (v&0x3F)<<8 | low
+			default:
+				low, _ := read(16)
+				v = (v&0x1F)<<16 | low
+			}
+			latin1 = v == 1 || v == 3
+		case 3: // concaténation structurée : indices ignorés
+			if _, ok := read(16); !ok {
+				return "", invalid
+			}
+		case 5: // FNC1 en première position
+		case 9: // FNC1 en seconde position
+			if _, ok := read(8); !ok {
+				return "", invalid
+			}
+		default:
+			return "", invalid
+		}
+	}
+	if sb.Len() == 0 {
+		return "", errNoCode
+	}
+	return sb.String(), nil
+}
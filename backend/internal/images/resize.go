@@ -0,0 +1,28 @@
+package images
+
+import (
+	"image"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// resize redimensionne img en width×height (Catmull-Rom), en conservant la profondeur source.
+func resize(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	if width == b.Dx() && height == b.Dy() {
+		return img
+	}
+	dst := newCanvasLike(img, image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, b, xdraw.Src, nil)
+	return dst
+}
+
+// resizeToWidth redimensionne img à la largeur donnée en gardant les proportions.
+func resizeToWidth(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	height := (b.Dy()*width + b.Dx()/2) / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+	return resize(img, width, height)
+}
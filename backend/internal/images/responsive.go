@@ -0,0 +1,260 @@
+package images
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"image"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ResponsiveOptions définit la famille d'images à produire pour un srcset
+type ResponsiveOptions struct {
+	Widths   []int    // largeurs à produire (jamais d'agrandissement)
+	Formats  []string // formats des <source>, le dernier sert de repli pour <img>
+	Sizes    string   // attribut sizes du HTML
+	Alt      string   // texte alternatif de l'image
+	BaseURL  string   // préfixe des URLs dans le HTML (ex. "/img/")
+	Encoding *Options // options d'encodage communes (le format est ignoré)
+
+	Placeholders bool // ajoute BlurHash, ThumbHash et LQIP au manifeste
+}
+
+// ResponsiveVariant décrit un fichier généré
+type ResponsiveVariant struct {
+	Format string `json:"format"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	File   string `json:"file"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ResponsiveSet est le résultat retourné et écrit dans le manifeste JSON
+type ResponsiveSet struct {
+	Source   string              `json:"source"`
+	Width    int                 `json:"width"`
+	Height   int                 `json:"height"`
+	Sizes    string              `json:"sizes"`
+	Variants []ResponsiveVariant `json:"variants"`
+	Manifest string              `json:"manifest"`
+	HTML     string              `json:"html"`
+
+	Placeholders *Placeholders `json:"placeholders,omitempty"`
+}
+
+var defaultResponsiveWidths = []int{320, 640, 960, 1280, 1920}
+var defaultResponsiveFormats = []string{"avif", "webp", "jpeg"}
+
+var mimeTypes = map[string]string{
+	"AVIF": "image/avif",
+	"WEBP": "image/webp",
+	"JPEG": "image/jpeg",
+	"JPG":  "image/jpeg",
+	"PNG":  "image/png",
+}
+
+// GenerateResponsiveSet produit, à partir d'une image, plusieurs largeurs dans plusieurs formats,
+// un manifeste JSON et un extrait <picture> prêt à coller.
+func GenerateResponsiveSet(path, outputDir string, ro ResponsiveOptions) (*ResponsiveSet, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("échec lecture '%s' : %w", path, err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("erreur de décodage de l'image : %w", err)
+	}
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return nil, fmt.Errorf("impossible de créer le dossier de sortie : %w", err)
+	}
+
+	// Options communes : le web attend du sRGB, le profil est donc toujours converti
+	base := Options{}
+	if ro.Encoding != nil {
+		base = *ro.Encoding
+	}
+	base.ColorProfile = ColorProfileSRGB
+	opts := applyDefaults(&base)
+	img, _ = applyColorProfile(img, extractICC(src), "", opts)
+	img, err = applyTransforms(img, exifOrientation(src), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	widths := responsiveWidths(ro.Widths, img.Bounds().Dx())
+	formats, err := responsiveFormats(ro.Formats)
+	if err != nil {
+		return nil, err
+	}
+
+	// Redimensionnement une seule fois par largeur
+	resized := make(map[int]image.Image, len(widths))
+	for _, w := range widths {
+		resized[w] = resizeToWidth(img, w)
+	}
+
+	baseName := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	type job struct {
+		width  int
+		format string
+	}
+	var jobs []job
+	for _, f := range formats {
+		for _, w := range widths {
+			jobs = append(jobs, job{w, f})
+		}
+	}
+
+	variants := make([]ResponsiveVariant, len(jobs))
+	jobCost := func(j job) int64 {
+		b := resized[j.width].Bounds()
+		return encodeMemory(b.Dx(), b.Dy(), strings.ToUpper(j.format), opts)
+	}
+	_, err = ParallelConvertWithCost(jobs, jobCost, func(i int, j job) ([]byte, error) {
+		variantOpts := *opts
+		variantOpts.Format = j.format
+		format := strings.ToUpper(j.format)
+
+		data, err := encodeImage(resized[j.width], nil, format, &variantOpts)
+		if err != nil {
+			return nil, err
+		}
+
+		name := fmt.Sprintf("%s-%d.%s", baseName, j.width, strings.ToLower(j.format))
+		if err := os.WriteFile(filepath.Join(outputDir, name), data, 0o644); err != nil {
+			return nil, fmt.Errorf("échec écriture '%s' : %w", name, err)
+		}
+
+		sum := sha256.Sum256(data)
+		b := resized[j.width].Bounds()
+		variants[i] = ResponsiveVariant{
+			Format: strings.ToLower(j.format),
+			Width:  b.Dx(),
+			Height: b.Dy(),
+			File:   name,
+			Size:   int64(len(data)),
+			SHA256: hex.EncodeToString(sum[:]),
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := ro.Sizes
+	if sizes == "" {
+		sizes = "100vw"
+	}
+	set := &ResponsiveSet{
+		Source:   path,
+		Width:    img.Bounds().Dx(),
+		Height:   img.Bounds().Dy(),
+		Sizes:    sizes,
+		Variants: variants,
+		Manifest: filepath.Join(outputDir, baseName+".manifest.json"),
+	}
+	set.HTML = buildPictureHTML(set, formats, ro.BaseURL, ro.Alt)
+
+	if ro.Placeholders {
+		set.Placeholders, err = ComputePlaceholders(img)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	manifest, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(set.Manifest, manifest, 0o644); err != nil {
+		return nil, fmt.Errorf("échec écriture du manifeste : %w", err)
+	}
+
+	return set, nil
+}
+
+// responsiveFormats normalise les formats demandés (minuscules, jpg → jpeg) et retire les
+// doublons, dans l'ordre de la demande.
+func responsiveFormats(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		requested = defaultResponsiveFormats
+	}
+	seen := map[string]bool{}
+	var formats []string
+	for _, f := range requested {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "jpg" {
+			f = "jpeg"
+		}
+		if _, ok := mimeTypes[strings.ToUpper(f)]; !ok {
+			return nil, fmt.Errorf("format '%s' non supporté pour le web", f)
+		}
+		if !seen[f] {
+			seen[f] = true
+			formats = append(formats, f)
+		}
+	}
+	return formats, nil
+}
+
+// responsiveWidths filtre les largeurs supérieures à la source ; la largeur source est
+// utilisée si aucune largeur demandée n'est atteignable.
+func responsiveWidths(requested []int, sourceWidth int) []int {
+	if len(requested) == 0 {
+		requested = defaultResponsiveWidths
+	}
+	seen := map[int]bool{}
+	var widths []int
+	for _, w := range requested {
+		if w > 0 && w <= sourceWidth && !seen[w] {
+			seen[w] = true
+			widths = append(widths, w)
+		}
+	}
+	if len(widths) == 0 {
+		widths = []int{sourceWidth}
+	}
+	sort.Ints(widths)
+	return widths
+}
+
+// buildPictureHTML génère l'élément <picture> : une <source> par format, le dernier format en <img>.
+func buildPictureHTML(set *ResponsiveSet, formats []string, baseURL, alt string) string {
+	srcset := func(format string) (string, ResponsiveVariant) {
+		var parts []string
+		var largest ResponsiveVariant
+		for _, v := range set.Variants {
+			if v.Format != strings.ToLower(format) {
+				continue
+			}
+			parts = append(parts, fmt.Sprintf("%s %dw", html.EscapeString(baseURL+v.File), v.Width))
+			if v.Width > largest.Width {
+				largest = v
+			}
+		}
+		return strings.Join(parts, ", "), largest
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<picture>\n")
+	for _, f := range formats[:len(formats)-1] {
+		s, _ := srcset(f)
+		fmt.Fprintf(&sb, "  <source type=\"%s\" srcset=\"%s\" sizes=\"%s\">\n",
+			mimeTypes[strings.ToUpper(f)], s, html.EscapeString(set.Sizes))
+	}
+
+	fallback := formats[len(formats)-1]
+	s, largest := srcset(fallback)
+	fmt.Fprintf(&sb, "  <img src=\"%s\" srcset=\"%s\" sizes=\"%s\" width=\"%d\" height=\"%d\" alt=\"%s\" loading=\"lazy\" decoding=\"async\">\n",
+		html.EscapeString(baseURL+largest.File), s, html.EscapeString(set.Sizes),
+		largest.Width, largest.Height, html.EscapeString(alt))
+	sb.WriteString("</picture>")
+	return sb.String()
+}
@@ -0,0 +1,170 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// applyOrientation redresse l'image selon l'orientation EXIF (1 à 8).
+func applyOrientation(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return flip(img, true, false)
+	case 3:
+		return rotateQuarter(img, 2)
+	case 4:
+		return flip(img, false, true)
+	case 5:
+		return flip(rotateQuarter(img, 1), true, false)
+	case 6:
+		return rotateQuarter(img, 1)
+	case 7:
+		return flip(rotateQuarter(img, 3), true, false)
+	case 8:
+		return rotateQuarter(img, 3)
+	}
+	return img
+}
+
+// applyRotation applique la rotation (en degrés, sens horaire) puis les miroirs demandés.
+func applyRotation(img image.Image, opts *Options) (image.Image, error) {
+	angle := math.Mod(opts.Rotate, 360)
+	if angle < 0 {
+		angle += 360
+	}
+
+	if angle != 0 {
+		if q := angle / 90; q == math.Trunc(q) {
+			img = rotateQuarter(img, int(q))
+		} else {
+			fill, err := parseHexColor(opts.FillColor, color.NRGBA{})
+			if err != nil {
+				return nil, err
+			}
+			img = rotateAny(img, angle, fill, !opts.RotateKeepSize)
+		}
+	}
+
+	if opts.FlipH || opts.FlipV {
+		img = flip(img, opts.FlipH, opts.FlipV)
+	}
+	return img, nil
+}
+
+// rotateQuarter tourne l'image de quarters × 90° dans le sens horaire.
+func rotateQuarter(img image.Image, quarters int) image.Image {
+	quarters = ((quarters % 4) + 4) % 4
+	if quarters == 0 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	dw, dh := w, h
+	if quarters%2 == 1 {
+		dw, dh = h, w
+	}
+	dst := newCanvasLike(img, image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.At(b.Min.X+x, b.Min.Y+y)
+			switch quarters {
+			case 1:
+				dst.Set(h-1-y, x, c)
+			case 2:
+				dst.Set(w-1-x, h-1-y, c)
+			case 3:
+				dst.Set(y, w-1-x, c)
+			}
+		}
+	}
+	return dst
+}
+
+// flip applique un miroir horizontal et/ou vertical.
+func flip(img image.Image, horizontal, vertical bool) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dst := newCanvasLike(img, image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		sy := y
+		if vertical {
+			sy = h - 1 - y
+		}
+		for x := 0; x < w; x++ {
+			sx := x
+			if horizontal {
+				sx = w - 1 - x
+			}
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+// rotateAny tourne l'image d'un angle quelconque (interpolation bilinéaire).
+// Avec expand, le canevas est agrandi pour contenir toute l'image ; les zones découvertes
+// prennent la couleur fill.
+func rotateAny(img image.Image, degrees float64, fill color.NRGBA, expand bool) image.Image {
+	b := img.Bounds()
+	w, h := float64(b.Dx()), float64(b.Dy())
+	theta := degrees * math.Pi / 180
+	cos, sin := math.Cos(theta), math.Sin(theta)
+
+	dw, dh := b.Dx(), b.Dy()
+	if expand {
+		dw = int(math.Ceil(math.Abs(w*cos) + math.Abs(h*sin) - 1e-9))
+		dh = int(math.Ceil(math.Abs(w*sin) + math.Abs(h*cos) - 1e-9))
+	}
+
+	// La couleur de fond peut être transparente : le canevas garde donc un canal alpha
+	dst := newCanvasLike(img, image.Rect(0, 0, dw, dh))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(fill), image.Point{}, draw.Src)
+
+	fr, fg, fb, fa := fill.RGBA()
+	background := [4]float64{float64(fr), float64(fg), float64(fb), float64(fa)}
+
+	sample := func(x, y int) [4]float64 {
+		if x < 0 || y < 0 || x >= b.Dx() || y >= b.Dy() {
+			return background
+		}
+		r, g, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+		return [4]float64{float64(r), float64(g), float64(bl), float64(a)}
+	}
+
+	cx, cy := w/2, h/2
+	dcx, dcy := float64(dw)/2, float64(dh)/2
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			// Transformation inverse : position du pixel dans l'image source
+			px, py := float64(x)+0.5-dcx, float64(y)+0.5-dcy
+			sx := cx + px*cos + py*sin - 0.5
+			sy := cy - px*sin + py*cos - 0.5
+
+			x0, y0 := int(math.Floor(sx)), int(math.Floor(sy))
+			if x0 < -1 || y0 < -1 || x0 >= b.Dx() || y0 >= b.Dy() {
+				continue
+			}
+			fx, fy := sx-float64(x0), sy-float64(y0)
+			c00, c10 := sample(x0, y0), sample(x0+1, y0)
+			c01, c11 := sample(x0, y0+1), sample(x0+1, y0+1)
+
+			var out [4]float64
+			for i := range out {
+				top := c00[i]*(1-fx) + c10[i]*fx
+				bottom := c01[i]*(1-fx) + c11[i]*fx
+				out[i] = top*(1-fy) + bottom*fy
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(math.Round(out[0])),
+				G: uint16(math.Round(out[1])),
+				B: uint16(math.Round(out[2])),
+				A: uint16(math.Round(out[3])),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,147 @@
+package images
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SplitOptions définit le découpage d'une image en morceaux
+type SplitOptions struct {
+	Columns    int      // nombre de colonnes pour un découpage en parts égales
+	Rows       int      // nombre de lignes
+	TileWidth  int      // largeur fixe d'un morceau (prioritaire sur Columns), le dernier peut être plus étroit
+	TileHeight int      // hauteur fixe d'un morceau (prioritaire sur Rows)
+	Overlap    int      // recouvrement en pixels entre morceaux voisins (collage d'affiches)
+	Format     string   // format de sortie (défaut : celui du fichier source)
+	Encoding   *Options // options d'encodage (le format est ignoré)
+}
+
+// SplitPiece décrit un morceau écrit sur le disque
+type SplitPiece struct {
+	File   string `json:"file"`
+	Row    int    `json:"row"`    // à partir de 1
+	Column int    `json:"column"` // à partir de 1
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// SplitResult liste les morceaux produits, ligne par ligne
+type SplitResult struct {
+	Rows    int          `json:"rows"`
+	Columns int          `json:"columns"`
+	Pieces  []SplitPiece `json:"pieces"`
+}
+
+// SplitImage découpe une image en grille et écrit chaque morceau sous <nom>_r<ligne>_c<colonne>.
+func SplitImage(path, outputDir string, opts SplitOptions) (*SplitResult, error) {
+	img, err := decodeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+
+	xs, err := splitBounds(b.Dx(), opts.Columns, opts.TileWidth)
+	if err != nil {
+		return nil, err
+	}
+	ys, err := splitBounds(b.Dy(), opts.Rows, opts.TileHeight)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Overlap < 0 {
+		opts.Overlap = 0
+	}
+
+	format := strings.ToUpper(opts.Format)
+	if format == "" {
+		format = strings.ToUpper(strings.TrimPrefix(filepath.Ext(path), "."))
+		if format == "TIF" {
+			format = "TIFF"
+		}
+		if !encodableFormats[format] {
+			format = "PNG"
+		}
+	}
+	if !encodableFormats[format] {
+		return nil, fmt.Errorf("format '%s' non supporté pour le découpage", strings.ToLower(format))
+	}
+	encOpts := Options{}
+	if opts.Encoding != nil {
+		encOpts = *opts.Encoding
+	}
+	encOpts.Format = format
+	applyDefaults(&encOpts)
+
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return nil, fmt.Errorf("impossible de créer le dossier de sortie : %w", err)
+	}
+
+	// Noms à largeur fixe pour garder l'ordre alphabétique
+	rows, cols := len(ys)-1, len(xs)-1
+	digits := len(fmt.Sprint(max(rows, cols)))
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	ext := strings.ToLower(format)
+
+	result := &SplitResult{Rows: rows, Columns: cols, Pieces: make([]SplitPiece, rows*cols)}
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			r := image.Rect(xs[col]-opts.Overlap, ys[row]-opts.Overlap, xs[col+1]+opts.Overlap, ys[row+1]+opts.Overlap).
+				Intersect(image.Rect(0, 0, b.Dx(), b.Dy()))
+			result.Pieces[row*cols+col] = SplitPiece{
+				File:   filepath.Join(outputDir, fmt.Sprintf("%s_r%0*d_c%0*d.%s", base, digits, row+1, digits, col+1, ext)),
+				Row:    row + 1,
+				Column: col + 1,
+				X:      r.Min.X,
+				Y:      r.Min.Y,
+				Width:  r.Dx(),
+				Height: r.Dy(),
+			}
+		}
+	}
+
+	pieceCost := func(p SplitPiece) int64 { return encodeMemory(p.Width, p.Height, format, &encOpts) }
+	_, err = ParallelConvertWithCost(result.Pieces, pieceCost, func(_ int, p SplitPiece) ([]byte, error) {
+		r := image.Rect(p.X, p.Y, p.X+p.Width, p.Y+p.Height).Add(b.Min)
+		data, err := encodeImage(cropTo(img, r), nil, format, &encOpts)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(p.File, data, 0o644); err != nil {
+			return nil, fmt.Errorf("échec écriture '%s' : %w", p.File, err)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// splitBounds retourne les limites des morceaux sur un axe : taille fixe si size > 0,
+// sinon count parts égales (le reste est réparti sur les morceaux).
+func splitBounds(length, count, size int) ([]int, error) {
+	if size <= 0 && count <= 0 {
+		count = 1 // axe non découpé (ex. panorama en 3×1)
+	}
+	if size > 0 {
+		count = ceilDiv(length, size)
+	}
+	if count > length {
+		return nil, fmt.Errorf("impossible de découper %d pixels en %d morceaux", length, count)
+	}
+
+	bounds := make([]int, count+1)
+	for i := range bounds {
+		if size > 0 {
+			bounds[i] = min(i*size, length)
+		} else {
+			bounds[i] = i * length / count
+		}
+	}
+	return bounds, nil
+}
@@ -0,0 +1,447 @@
+package images
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/image/tiff/lzw"
+)
+
+// tiffRows lit un TIFF bande par bande (ou rangée de tuiles par rangée de tuiles) sans
+// décoder l'image entière. Les variantes non prises en charge (JPEG, CMJN, plans séparés,
+// prédicteur flottant...) renvoient errNotStreamable et passent par le décodage complet.
+type tiffRows struct {
+	f  *os.File
+	bo binary.ByteOrder
+
+	w, h         int
+	bits, spp    int
+	photometric  uint64
+	compression  uint64
+	predictor    uint64
+	alpha        uint64 // 0 : aucun, 1 : associé (prémultiplié), 2 : non associé
+	colormap     []uint16
+	offsets      []uint64
+	counts       []uint64
+	rowsPerStrip int
+	tileW, tileH int // 0 pour un TIFF en bandes
+
+	y       int
+	strip   io.Reader // bande en cours de lecture
+	closer  io.Closer
+	raw     []byte // une ligne de la bande ou de la tuile
+	band    []byte // rangée de tuiles convertie en RGBA (TIFF en tuiles)
+	bandTop int
+}
+
+// Types de valeurs TIFF entières et leur taille en octets
+var tiffTypeSize = map[uint16]int{1: 1, 3: 2, 4: 4, 13: 4, 16: 8, 18: 8}
+
+func newTIFFRows(f *os.File) (*tiffRows, error) {
+	var head [16]byte
+	if _, err := io.ReadFull(f, head[:8]); err != nil {
+		return nil, fmt.Errorf("TIFF tronqué : %w", err)
+	}
+	t := &tiffRows{f: f, bo: binary.LittleEndian}
+	if head[0] == 'M' {
+		t.bo = binary.BigEndian
+	}
+	big := t.bo.Uint16(head[2:]) == 43
+	ifd := uint64(t.bo.Uint32(head[4:]))
+	if big {
+		if _, err := io.ReadFull(f, head[8:16]); err != nil {
+			return nil, fmt.Errorf("TIFF tronqué : %w", err)
+		}
+		ifd = t.bo.Uint64(head[8:])
+	}
+
+	tags, err := t.readIFD(ifd, big)
+	if err != nil {
+		return nil, err
+	}
+	return t, t.setup(tags)
+}
+
+// readIFD lit les valeurs entières du premier IFD, indexées par numéro de tag.
+func (t *tiffRows) readIFD(offset uint64, big bool) (map[uint16][]uint64, error) {
+	countSize, entrySize, inline := 2, 12, 4
+	if big {
+		countSize, entrySize, inline = 8, 20, 8
+	}
+	buf := make([]byte, countSize)
+	if _, err := t.f.ReadAt(buf, int64(offset)); err != nil {
+		return nil, fmt.Errorf("IFD TIFF illisible : %w", err)
+	}
+	var n uint64
+	if big {
+		n = t.bo.Uint64(buf)
+	} else {
+		n = uint64(t.bo.Uint16(buf))
+	}
+	if n > 4096 {
+		return nil, errors.New("IFD TIFF invalide")
+	}
+	entries := make([]byte, int(n)*entrySize)
+	if _, err := t.f.ReadAt(entries, int64(offset)+int64(countSize)); err != nil {
+		return nil, fmt.Errorf("IFD TIFF illisible : %w", err)
+	}
+
+	tags := map[uint16][]uint64{}
+	for i := 0; i < int(n); i++ {
+		e := entries[i*entrySize:]
+		tag, typ := t.bo.Uint16(e), t.bo.Uint16(e[2:])
+		size, ok := tiffTypeSize[typ]
+		if !ok {
+			continue // ASCII, rationnels... inutiles ici
+		}
+		var count uint64
+		var value []byte
+		if big {
+			count, value = t.bo.Uint64(e[4:]), e[12:20]
+		} else {
+			count, value = uint64(t.bo.Uint32(e[4:])), e[8:12]
+		}
+		if count > 1<<26 {
+			return nil, fmt.Errorf("tag TIFF %d trop grand", tag)
+		}
+		data := value[:min(len(value), int(count)*size)]
+		if int(count)*size > inline {
+			var at uint64
+			if big {
+				at = t.bo.Uint64(value)
+			} else {
+				at = uint64(t.bo.Uint32(value))
+			}
+			data = make([]byte, int(count)*size)
+			if _, err := t.f.ReadAt(data, int64(at)); err != nil {
+				return nil, fmt.Errorf("tag TIFF %d illisible : %w", tag, err)
+			}
+		}
+		vals := make([]uint64, count)
+		for j := range vals {
+			switch size {
+			case 1:
+				vals[j] = uint64(data[j])
+			case 2:
+				vals[j] = uint64(t.bo.Uint16(data[2*j:]))
+			case 4:
+				vals[j] = uint64(t.bo.Uint32(data[4*j:]))
+			case 8:
+				vals[j] = t.bo.Uint64(data[8*j:])
+			}
+		}
+		tags[tag] = vals
+	}
+	return tags, nil
+}
+
+func (t *tiffRows) setup(tags map[uint16][]uint64) error {
+	first := func(tag uint16, def uint64) uint64 {
+		if v := tags[tag]; len(v) > 0 {
+			return v[0]
+		}
+		return def
+	}
+	w, h := first(256, 0), first(257, 0)
+	if w == 0 || h == 0 || w > 1<<30 || h > 1<<30 {
+		return errors.New("dimensions TIFF invalides")
+	}
+	t.w, t.h = int(w), int(h)
+	t.spp = int(first(277, 1))
+	t.bits = int(first(258, 1))
+	for _, b := range tags[258] {
+		if int(b) != t.bits {
+			return errNotStreamable
+		}
+	}
+	t.photometric = first(262, 1)
+	t.compression = first(259, 1)
+	t.predictor = first(317, 1)
+
+	if first(284, 1) != 1 || first(266, 1) != 1 || first(274, 1) != 1 || first(339, 1) != 1 {
+		return errNotStreamable // plans séparés, bits inversés, rotation ou échantillons non entiers
+	}
+	switch t.compression {
+	case 1, 5, 8, 32946, 32773:
+	default:
+		return errNotStreamable
+	}
+	if t.predictor != 1 && (t.predictor != 2 || t.bits < 8) {
+		return errNotStreamable
+	}
+
+	base := 1
+	switch t.photometric {
+	case 0, 1:
+		if t.bits != 1 && t.bits != 2 && t.bits != 4 && t.bits != 8 && t.bits != 16 {
+			return errNotStreamable
+		}
+	case 2:
+		base = 3
+		if t.bits != 8 && t.bits != 16 {
+			return errNotStreamable
+		}
+	case 3:
+		if t.bits > 8 {
+			return errNotStreamable
+		}
+		t.colormap = make([]uint16, len(tags[320]))
+		for i, v := range tags[320] {
+			t.colormap[i] = uint16(v)
+		}
+		if len(t.colormap) < 3<<t.bits {
+			return errors.New("palette TIFF absente")
+		}
+	default:
+		return errNotStreamable
+	}
+	if t.spp < base {
+		return errors.New("nombre d'échantillons TIFF invalide")
+	}
+	if extra := tags[338]; t.spp > base && len(extra) > 0 && (extra[0] == 1 || extra[0] == 2) {
+		t.alpha = extra[0]
+	}
+
+	if tags[322] != nil {
+		t.tileW, t.tileH = int(first(322, 0)), int(first(323, 0))
+		if t.tileW <= 0 || t.tileH <= 0 || t.tileW > 1<<16 || t.tileH > 1<<16 {
+			return errors.New("taille de tuile TIFF invalide")
+		}
+		t.offsets, t.counts = tags[324], tags[325]
+		if len(t.offsets) < ceilDiv(t.w, t.tileW)*ceilDiv(t.h, t.tileH) || len(t.counts) < len(t.offsets) {
+			return errors.New("tuiles TIFF manquantes")
+		}
+		t.raw = make([]byte, t.tileH*t.lineBytes(t.tileW))
+		t.band = make([]byte, t.tileH*4*t.w)
+		t.bandTop = -t.tileH
+		return nil
+	}
+
+	t.rowsPerStrip = int(min(first(278, h), h))
+	t.offsets, t.counts = tags[273], tags[279]
+	if t.rowsPerStrip <= 0 || len(t.offsets) < ceilDiv(t.h, t.rowsPerStrip) || len(t.counts) < len(t.offsets) {
+		return errors.New("bandes TIFF manquantes")
+	}
+	t.raw = make([]byte, t.lineBytes(t.w))
+	return nil
+}
+
+// lineBytes est la taille d'une ligne de n pixels dans le fichier
+func (t *tiffRows) lineBytes(n int) int {
+	return (n*t.spp*t.bits + 7) / 8
+}
+
+func (t *tiffRows) size() (int, int) { return t.w, t.h }
+
+func (t *tiffRows) Close() error {
+	if t.closer != nil {
+		t.closer.Close()
+	}
+	return t.f.Close()
+}
+
+// open renvoie un lecteur décompressé du segment i (bande ou tuile)
+func (t *tiffRows) open(i int) (io.Reader, io.Closer, error) {
+	r := bufio.NewReader(io.NewSectionReader(t.f, int64(t.offsets[i]), int64(t.counts[i])))
+	switch t.compression {
+	case 5:
+		lr := lzw.NewReader(r, lzw.MSB, 8)
+		return lr, lr, nil
+	case 8, 32946:
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("données TIFF compressées invalides : %w", err)
+		}
+		return zr, zr, nil
+	case 32773:
+		return &packBitsReader{r: r}, nil, nil
+	}
+	return r, nil, nil
+}
+
+func (t *tiffRows) readRow(dst []uint8) error {
+	defer func() { t.y++ }()
+	if t.tileW > 0 {
+		if t.y >= t.bandTop+t.tileH {
+			if err := t.readTileRow(t.y / t.tileH); err != nil {
+				return err
+			}
+		}
+		copy(dst, t.band[(t.y-t.bandTop)*4*t.w:])
+		return nil
+	}
+
+	if t.y%t.rowsPerStrip == 0 {
+		if t.closer != nil {
+			t.closer.Close()
+		}
+		var err error
+		if t.strip, t.closer, err = t.open(t.y / t.rowsPerStrip); err != nil {
+			return err
+		}
+	}
+	if _, err := io.ReadFull(t.strip, t.raw); err != nil {
+		return fmt.Errorf("bande TIFF tronquée : %w", err)
+	}
+	t.unpredict(t.raw)
+	t.convert(t.raw, t.w, dst)
+	return nil
+}
+
+// readTileRow décode toutes les tuiles de la rangée row dans t.band.
+func (t *tiffRows) readTileRow(row int) error {
+	across := ceilDiv(t.w, t.tileW)
+	line := t.lineBytes(t.tileW)
+	rgba := make([]byte, 4*t.tileW)
+	for col := 0; col < across; col++ {
+		r, c, err := t.open(row*across + col)
+		if err != nil {
+			return err
+		}
+		_, err = io.ReadFull(r, t.raw)
+		if c != nil {
+			c.Close()
+		}
+		if err != nil {
+			return fmt.Errorf("tuile TIFF tronquée : %w", err)
+		}
+		x0 := col * t.tileW
+		n := min(t.tileW, t.w-x0)
+		for y := 0; y < t.tileH; y++ {
+			src := t.raw[y*line : (y+1)*line]
+			t.unpredict(src)
+			t.convert(src, n, rgba)
+			copy(t.band[y*4*t.w+4*x0:], rgba[:4*n])
+		}
+	}
+	t.bandTop = row * t.tileH
+	return nil
+}
+
+// unpredict annule le prédicteur horizontal sur une ligne
+func (t *tiffRows) unpredict(line []byte) {
+	if t.predictor != 2 {
+		return
+	}
+	if t.bits == 8 {
+		for i := t.spp; i < len(line); i++ {
+			line[i] += line[i-t.spp]
+		}
+		return
+	}
+	for i := 2 * t.spp; i+1 < len(line); i += 2 {
+		t.bo.PutUint16(line[i:], t.bo.Uint16(line[i:])+t.bo.Uint16(line[i-2*t.spp:]))
+	}
+}
+
+// convert écrit n pixels de la ligne src en RGBA prémultiplié dans dst.
+func (t *tiffRows) convert(src []byte, n int, dst []uint8) {
+	sample := func(x, c int) uint16 {
+		i := x*t.spp + c
+		switch t.bits {
+		case 16:
+			return t.bo.Uint16(src[2*i:])
+		case 8:
+			return uint16(src[i])
+		}
+		bit := i * t.bits
+		return uint16(src[bit/8]>>(8-t.bits-bit%8)) & (1<<t.bits - 1)
+	}
+	to8 := func(v uint16) uint8 {
+		switch t.bits {
+		case 16:
+			return uint8(v >> 8)
+		case 8:
+			return uint8(v)
+		}
+		return uint8(uint32(v) * 255 / (1<<t.bits - 1))
+	}
+	// withAlpha prémultiplie si besoin et borne les composantes par l'alpha
+	withAlpha := func(px []uint8, a uint8) {
+		for i := 0; i < 3; i++ {
+			if t.alpha == 2 {
+				px[i] = premul(px[i], a)
+			}
+			px[i] = min(px[i], a)
+		}
+		px[3] = a
+	}
+
+	for x := 0; x < n; x++ {
+		px := dst[4*x : 4*x+4]
+		switch t.photometric {
+		case 0, 1:
+			g := to8(sample(x, 0))
+			if t.photometric == 0 {
+				g = 255 - g
+			}
+			px[0], px[1], px[2], px[3] = g, g, g, 255
+			if t.alpha != 0 {
+				withAlpha(px, to8(sample(x, 1)))
+			}
+		case 2:
+			px[0], px[1], px[2], px[3] = to8(sample(x, 0)), to8(sample(x, 1)), to8(sample(x, 2)), 255
+			if t.alpha != 0 {
+				withAlpha(px, to8(sample(x, 3)))
+			}
+		case 3:
+			i, size := int(sample(x, 0)), 1<<t.bits
+			px[0] = uint8(t.colormap[i] >> 8)
+			px[1] = uint8(t.colormap[size+i] >> 8)
+			px[2] = uint8(t.colormap[2*size+i] >> 8)
+			px[3] = 255
+		}
+	}
+}
+
+// packBitsReader décompresse un flux PackBits au fil de la lecture.
+type packBitsReader struct {
+	r   io.ByteReader
+	run int
+	lit bool
+	val byte
+}
+
+func (p *packBitsReader) Read(b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		if p.run == 0 {
+			h, err := p.r.ReadByte()
+			if err != nil {
+				if n > 0 {
+					return n, nil
+				}
+				return 0, err
+			}
+			switch {
+			case h < 0x80:
+				p.run, p.lit = int(h)+1, true
+			case h == 0x80:
+				continue
+			default:
+				v, err := p.r.ReadByte()
+				if err != nil {
+					return n, io.ErrUnexpectedEOF
+				}
+				p.run, p.lit, p.val = 257-int(h), false, v
+			}
+		}
+		if p.lit {
+			c, err := p.r.ReadByte()
+			if err != nil {
+				return n, io.ErrUnexpectedEOF
+			}
+			b[n] = c
+		} else {
+			b[n] = p.val
+		}
+		n++
+		p.run--
+	}
+	return n, nil
+}
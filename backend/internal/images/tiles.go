@@ -0,0 +1,366 @@
+package images
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	TileLayoutDZI  = "dzi"  // Deep Zoom (OpenSeadragon) : <nom>.dzi + <nom>_files/<niveau>/<col>_<ligne>
+	TileLayoutIIIF = "iiif" // IIIF Image API 2.1 niveau 0 : <nom>/info.json + <région>/<taille>/0/default
+)
+
+// TileOptions définit la pyramide de tuiles à produire
+type TileOptions struct {
+	Layout   string   // "dzi" (défaut) ou "iiif"
+	TileSize int      // côté d'une tuile en pixels (254 par défaut en DZI, 512 en IIIF)
+	Overlap  int      // recouvrement entre tuiles voisines (DZI uniquement, 1 par défaut, négatif = aucun)
+	Format   string   // "jpeg" (défaut), "png" ou "webp"
+	Name     string   // nom de la pyramide (défaut : nom du fichier source)
+	BaseURL  string   // URL publique du dossier de sortie, utilisée comme @id en IIIF
+	Encoding *Options // options d'encodage des tuiles (le format est ignoré)
+}
+
+// TileResult résume la pyramide générée
+type TileResult struct {
+	Descriptor string `json:"descriptor"` // fichier .dzi ou info.json
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	Levels     int    `json:"levels"`
+	Tiles      int    `json:"tiles"`
+}
+
+type tileJob struct {
+	rect image.Rectangle // zone dans l'image du niveau
+	path string
+}
+
+// GenerateTiles découpe une image en pyramide de tuiles pour l'affichage en zoom profond.
+// Les PNG non entrelacés et les TIFF (bandes ou tuiles, non compressés, LZW, Deflate ou
+// PackBits) sont lus par bandes de lignes : chaque niveau est réduit de moitié à partir du
+// précédent au fil de la lecture et ne garde que les lignes de la rangée de tuiles en cours.
+// La mémoire dépend alors de la largeur de l'image et non de sa surface, ce qui permet de
+// traiter des sources de plusieurs gigapixels. Les autres formats sont décodés entièrement,
+// dans la limite du budget mémoire (ParallelLimits). Les tuiles sont produites en 8 bits et
+// celles d'une même rangée sont encodées en parallèle.
+func GenerateTiles(path, outputDir string, opts TileOptions) (*TileResult, error) {
+	opts.Layout = strings.ToLower(opts.Layout)
+	if opts.Layout == "" {
+		opts.Layout = TileLayoutDZI
+	}
+	if opts.Layout != TileLayoutDZI && opts.Layout != TileLayoutIIIF {
+		return nil, fmt.Errorf("disposition de tuiles '%s' non supportée", opts.Layout)
+	}
+	if opts.TileSize <= 0 {
+		opts.TileSize = 254
+		if opts.Layout == TileLayoutIIIF {
+			opts.TileSize = 512
+		}
+	}
+	if opts.Overlap == 0 {
+		opts.Overlap = 1
+	}
+	if opts.Overlap < 0 || opts.Layout == TileLayoutIIIF {
+		opts.Overlap = 0
+	}
+	if opts.Format == "" {
+		opts.Format = "jpeg"
+	}
+	format := strings.ToUpper(opts.Format)
+	if format != "JPEG" && format != "JPG" && format != "PNG" && format != "WEBP" {
+		return nil, fmt.Errorf("format de tuile '%s' non supporté", opts.Format)
+	}
+	ext := strings.ToLower(opts.Format)
+	if opts.Layout == TileLayoutIIIF && (format == "JPEG" || format == "JPG") {
+		ext = "jpg" // extension imposée par la spécification IIIF
+	}
+	if opts.Name == "" {
+		opts.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	encOpts := Options{}
+	if opts.Encoding != nil {
+		encOpts = *opts.Encoding
+	}
+	encOpts.Format = format
+	applyDefaults(&encOpts)
+
+	src, err := openRowSource(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	width, height := src.size()
+
+	// Fenêtres de lignes de tous les niveaux (la somme des niveaux réduits vaut au plus le premier)
+	if limit := GetParallelLimits().MemoryBudget; limit > 0 {
+		if need := 2 * 4 * int64(width) * int64(opts.TileSize+2*opts.Overlap+2+tileBatch); need > limit {
+			return nil, fmt.Errorf("image trop large pour le budget mémoire (%d Mo nécessaires, %d Mo autorisés)", need>>20, limit>>20)
+		}
+	}
+
+	// Nombre de niveaux : DZI descend jusqu'à 1×1, IIIF s'arrête quand une tuile couvre l'image
+	maxLevel := 0
+	for (width-1)>>maxLevel > 0 || (height-1)>>maxLevel > 0 {
+		maxLevel++
+	}
+	if opts.Layout == TileLayoutIIIF {
+		maxLevel = 0
+		for ceilDiv(width, 1<<maxLevel) > opts.TileSize || ceilDiv(height, 1<<maxLevel) > opts.TileSize {
+			maxLevel++
+		}
+	}
+
+	root := filepath.Join(outputDir, opts.Name+"_files")
+	if opts.Layout == TileLayoutIIIF {
+		root = filepath.Join(outputDir, opts.Name)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("impossible de créer le dossier de sortie : %w", err)
+	}
+
+	t := &tiler{opts: opts, root: root, ext: ext, format: format, encOpts: &encOpts, width: width, height: height}
+	for step := 0; step <= maxLevel; step++ {
+		l := &tileLevel{step: step, w: ceilDiv(width, 1<<step), h: ceilDiv(height, 1<<step)}
+		if opts.Layout == TileLayoutDZI {
+			l.dir = filepath.Join(root, fmt.Sprint(maxLevel-step))
+			if err := os.MkdirAll(l.dir, 0o755); err != nil {
+				return nil, fmt.Errorf("impossible de créer le dossier '%s' : %w", l.dir, err)
+			}
+		}
+		t.levels = append(t.levels, l)
+	}
+
+	// Lecture de la source par paquets de lignes, propagés dans la pyramide
+	batch := make([]uint8, 0, 4*width*tileBatch)
+	for y := 0; y < height; y++ {
+		row := batch[len(batch) : len(batch)+4*width]
+		if err := src.readRow(row); err != nil {
+			return nil, fmt.Errorf("erreur de décodage de '%s' : %w", path, err)
+		}
+		batch = batch[:len(batch)+4*width]
+		if len(batch) == cap(batch) || y == height-1 {
+			if err := t.push(0, batch); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	result := &TileResult{Width: width, Height: height, Levels: maxLevel + 1, Tiles: t.tiles}
+	if opts.Layout == TileLayoutDZI {
+		result.Descriptor = filepath.Join(outputDir, opts.Name+".dzi")
+		err = os.WriteFile(result.Descriptor, []byte(dziDescriptor(width, height, opts.TileSize, opts.Overlap, ext)), 0o644)
+	} else {
+		result.Descriptor = filepath.Join(root, "info.json")
+		var info []byte
+		info, err = iiifInfo(strings.TrimSuffix(opts.BaseURL, "/")+"/"+opts.Name, width, height, opts.TileSize, maxLevel, ext)
+		if err == nil {
+			err = os.WriteFile(result.Descriptor, info, 0o644)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("échec écriture du descripteur : %w", err)
+	}
+	return result, nil
+}
+
+// tileBatch est le nombre de lignes source lues avant de les propager dans la pyramide
+const tileBatch = 16
+
+// tiler construit la pyramide au fil des lignes : chaque niveau ne garde que les lignes
+// encore utiles à sa prochaine rangée de tuiles et au calcul du niveau suivant.
+type tiler struct {
+	opts          TileOptions
+	root, ext     string
+	format        string
+	encOpts       *Options
+	width, height int
+	levels        []*tileLevel
+	tiles         int
+}
+
+type tileLevel struct {
+	step     int
+	w, h     int
+	dir      string  // dossier du niveau (DZI)
+	top      int     // indice de la première ligne gardée
+	pix      []uint8 // lignes gardées, RGBA prémultiplié
+	nextRow  int     // prochaine rangée de tuiles à écrire
+	nextHalf int     // prochaine ligne du niveau suivant à calculer
+}
+
+// push ajoute des lignes au niveau k, écrit les rangées de tuiles complètes et propage
+// les lignes réduites de moitié au niveau suivant.
+func (t *tiler) push(k int, rows []uint8) error {
+	l := t.levels[k]
+	stride := 4 * l.w
+	l.pix = append(l.pix, rows...)
+	bottom := l.top + len(l.pix)/stride
+	size, overlap := t.opts.TileSize, t.opts.Overlap
+
+	for l.nextRow*size < l.h && min(l.h, (l.nextRow+1)*size+overlap) <= bottom {
+		if err := t.writeRow(l, bottom); err != nil {
+			return err
+		}
+		l.nextRow++
+	}
+
+	keep := l.nextRow*size - overlap
+	if k+1 < len(t.levels) {
+		next := t.levels[k+1]
+		var half []uint8
+		for l.nextHalf < next.h && min(2*l.nextHalf+1, l.h-1) < bottom {
+			half = t.appendHalf(half, l, next.w, 2*l.nextHalf)
+			l.nextHalf++
+		}
+		if len(half) > 0 {
+			if err := t.push(k+1, half); err != nil {
+				return err
+			}
+		}
+		keep = min(keep, 2*l.nextHalf)
+	}
+
+	// Lignes devenues inutiles
+	if drop := min(keep, bottom) - l.top; drop > 0 {
+		n := copy(l.pix, l.pix[drop*stride:])
+		l.pix = l.pix[:n]
+		l.top += drop
+	}
+	return nil
+}
+
+// appendHalf ajoute à dst la ligne y/2 du niveau suivant : moyenne de chaque bloc de 2×2
+// pixels, le dernier pixel étant répété sur un bord impair.
+func (t *tiler) appendHalf(dst []uint8, l *tileLevel, w2, y int) []uint8 {
+	stride := 4 * l.w
+	r0 := l.pix[(y-l.top)*stride:]
+	r1 := l.pix[(min(y+1, l.h-1)-l.top)*stride:]
+	for x := 0; x < w2; x++ {
+		a, b := 4*2*x, 4*min(2*x+1, l.w-1)
+		for c := 0; c < 4; c++ {
+			sum := int(r0[a+c]) + int(r0[b+c]) + int(r1[a+c]) + int(r1[b+c])
+			dst = append(dst, uint8((sum+2)/4))
+		}
+	}
+	return dst
+}
+
+// writeRow encode en parallèle les tuiles de la rangée l.nextRow.
+func (t *tiler) writeRow(l *tileLevel, bottom int) error {
+	view := &image.RGBA{Pix: l.pix, Stride: 4 * l.w, Rect: image.Rect(0, l.top, l.w, bottom)}
+	b := image.Rect(0, 0, l.w, l.h)
+
+	var jobs []tileJob
+	var err error
+	if t.opts.Layout == TileLayoutDZI {
+		jobs = dziJobs(b, l.dir, l.nextRow, t.opts.TileSize, t.opts.Overlap, t.ext)
+	} else {
+		jobs, err = iiifJobs(b, t.root, l.step, l.nextRow, t.width, t.height, t.opts.TileSize, t.ext)
+		if err != nil {
+			return err
+		}
+	}
+
+	format, encOpts := t.format, t.encOpts
+	tileCost := func(j tileJob) int64 { return encodeMemory(j.rect.Dx(), j.rect.Dy(), format, encOpts) }
+	_, err = ParallelConvertWithCost(jobs, tileCost, func(_ int, j tileJob) ([]byte, error) {
+		var tile image.Image = view.SubImage(j.rect)
+		if format != "JPEG" && format != "JPG" {
+			// Transparence : les encodeurs PNG et WebP attendent des couleurs non prémultipliées
+			nrgba := image.NewNRGBA(image.Rect(0, 0, j.rect.Dx(), j.rect.Dy()))
+			draw.Draw(nrgba, nrgba.Rect, tile, j.rect.Min, draw.Src)
+			tile = nrgba
+		}
+		data, err := encodeImage(tile, nil, format, encOpts)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(j.path, data, 0o644); err != nil {
+			return nil, fmt.Errorf("échec écriture '%s' : %w", j.path, err)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return err
+	}
+	t.tiles += len(jobs)
+	return nil
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
+
+// dziJobs liste les tuiles de la rangée row d'un niveau DZI, recouvrement compris.
+func dziJobs(b image.Rectangle, dir string, row, size, overlap int, ext string) []tileJob {
+	var jobs []tileJob
+	for col := 0; col*size < b.Dx(); col++ {
+		r := image.Rect(col*size-overlap, row*size-overlap, (col+1)*size+overlap, (row+1)*size+overlap)
+		jobs = append(jobs, tileJob{
+			rect: r.Add(b.Min).Intersect(b),
+			path: filepath.Join(dir, fmt.Sprintf("%d_%d.%s", col, row, ext)),
+		})
+	}
+	return jobs
+}
+
+// iiifJobs liste les tuiles de la rangée row au facteur d'échelle 2^step : les régions sont
+// exprimées en pixels de l'image pleine résolution, comme l'attend un client IIIF.
+func iiifJobs(b image.Rectangle, root string, step, row, width, height, size int, ext string) ([]tileJob, error) {
+	scale := 1 << step
+	y := row * size * scale
+	var jobs []tileJob
+	for x := 0; x < width; x += size * scale {
+		rw, rh := min(size*scale, width-x), min(size*scale, height-y)
+		r := image.Rect(x/scale, y/scale, x/scale+ceilDiv(rw, scale), y/scale+ceilDiv(rh, scale))
+
+		regions := []string{fmt.Sprintf("%d,%d,%d,%d", x, y, rw, rh)}
+		if rw == width && rh == height {
+			regions = append(regions, "full") // demandé par les clients quand une tuile couvre l'image
+		}
+		for _, region := range regions {
+			dir := filepath.Join(root, region, fmt.Sprintf("%d,", r.Dx()), "0")
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return nil, fmt.Errorf("impossible de créer le dossier '%s' : %w", dir, err)
+			}
+			jobs = append(jobs, tileJob{
+				rect: r.Add(b.Min).Intersect(b),
+				path: filepath.Join(dir, "default."+ext),
+			})
+		}
+	}
+	return jobs, nil
+}
+
+func dziDescriptor(width, height, size, overlap int, ext string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<Image xmlns="http://schemas.microsoft.com/deepzoom/2008" Format="%s" Overlap="%d" TileSize="%d">
+  <Size Width="%d" Height="%d"/>
+</Image>
+`, html.EscapeString(ext), overlap, size, width, height)
+}
+
+func iiifInfo(id string, width, height, size, maxLevel int, ext string) ([]byte, error) {
+	factors := make([]int, maxLevel+1)
+	for i := range factors {
+		factors[i] = 1 << i
+	}
+	info := map[string]any{
+		"@context": "http://iiif.io/api/image/2/context.json",
+		"@id":      id,
+		"protocol": "http://iiif.io/api/image",
+		"width":    width,
+		"height":   height,
+		"profile":  []any{"http://iiif.io/api/image/2/level0.json", map[string]any{"formats": []string{ext}}},
+		"tiles":    []map[string]any{{"width": size, "scaleFactors": factors}},
+	}
+	return json.MarshalIndent(info, "", "  ")
+}
@@ -0,0 +1,332 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/tiff"
+)
+
+// readAllRows lit toutes les lignes d'une source dans une image RGBA.
+func readAllRows(t *testing.T, path string) *image.RGBA {
+	t.Helper()
+	src, err := openRowSource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	w, h := src.size()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		if err := src.readRow(img.Pix[y*img.Stride : (y+1)*img.Stride]); err != nil {
+			t.Fatalf("ligne %d : %v", y, err)
+		}
+	}
+	return img
+}
+
+// assertSameRGBA compare deux images à ±1 près (arrondis de prémultiplication).
+func assertSameRGBA(t *testing.T, name string, got *image.RGBA, want image.Image) {
+	t.Helper()
+	ref := image.NewRGBA(want.Bounds())
+	draw.Draw(ref, ref.Rect, want, want.Bounds().Min, draw.Src)
+	if got.Rect.Size() != ref.Rect.Size() {
+		t.Fatalf("%s : taille %v, attendu %v", name, got.Rect.Size(), ref.Rect.Size())
+	}
+	for i := range ref.Pix {
+		if d := int(got.Pix[i]) - int(ref.Pix[i]); d < -1 || d > 1 {
+			x, y := i%ref.Stride/4, i/ref.Stride
+			t.Fatalf("%s : pixel (%d,%d) canal %d = %d, attendu %d", name, x, y, i%4, got.Pix[i], ref.Pix[i])
+		}
+	}
+}
+
+// testImages couvre les modèles de couleur courants, avec transparence partielle.
+func testImages() map[string]image.Image {
+	photo := testPhoto(37, 23)
+	out := map[string]image.Image{"nrgba-opaque": photo}
+
+	nrgba := image.NewNRGBA(photo.Rect)
+	nrgba64 := image.NewNRGBA64(photo.Rect)
+	gray := image.NewGray(photo.Rect)
+	gray16 := image.NewGray16(photo.Rect)
+	pal := image.NewPaletted(photo.Rect, color.Palette{
+		color.NRGBA{0, 0, 0, 0}, color.NRGBA{255, 0, 0, 128}, color.NRGBA{10, 200, 30, 255}, color.NRGBA{90, 90, 250, 40},
+	})
+	bw := image.NewPaletted(photo.Rect, color.Palette{color.Black, color.White})
+	for y := 0; y < 23; y++ {
+		for x := 0; x < 37; x++ {
+			c := photo.NRGBAAt(x, y)
+			c.A = uint8(x * 7)
+			nrgba.SetNRGBA(x, y, c)
+			nrgba64.Set(x, y, color.NRGBA64{uint16(c.R) * 257, uint16(c.G)*257 + 3, uint16(c.B) * 250, uint16(y) * 2800})
+			gray.Set(x, y, c)
+			gray16.SetGray16(x, y, color.Gray16{uint16(x*y) * 70})
+			pal.SetColorIndex(x, y, uint8((x+y)%4))
+			bw.SetColorIndex(x, y, uint8((x/3+y)%2))
+		}
+	}
+	out["nrgba"], out["nrgba64"], out["gray"], out["gray16"], out["palette"], out["1bit"] =
+		nrgba, nrgba64, gray, gray16, pal, bw
+	return out
+}
+
+func TestPNGRowsMatchDecoder(t *testing.T) {
+	dir := t.TempDir()
+	for name, img := range testImages() {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name+".png")
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := newPNGRows(mustOpen(t, path)); err != nil {
+			t.Fatalf("%s : PNG non lu par bandes : %v", name, err)
+		}
+		ref, err := png.Decode(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSameRGBA(t, "png/"+name, readAllRows(t, path), ref)
+	}
+}
+
+func TestTIFFRowsMatchDecoder(t *testing.T) {
+	dir := t.TempDir()
+	variants := map[string]*tiff.Options{
+		"brut":            {Compression: tiff.Uncompressed},
+		"deflate":         {Compression: tiff.Deflate},
+		"deflate-predict": {Compression: tiff.Deflate, Predictor: true},
+	}
+	for name, img := range testImages() {
+		for variant, opts := range variants {
+			var buf bytes.Buffer
+			if err := tiff.Encode(&buf, img, opts); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, name+"-"+variant+".tif")
+			if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := newTIFFRows(mustOpen(t, path)); err != nil {
+				t.Fatalf("%s/%s : TIFF non lu par bandes : %v", name, variant, err)
+			}
+			ref, err := tiff.Decode(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertSameRGBA(t, "tiff/"+name+"/"+variant, readAllRows(t, path), ref)
+		}
+	}
+}
+
+// TIFF RVB en tuiles de 16×8, non compressé, construit à la main (l'encodeur n'écrit que des bandes).
+func TestTIFFTiledRows(t *testing.T) {
+	src := testPhoto(37, 23)
+	const tw, th = 16, 8
+	across, down := ceilDiv(37, tw), ceilDiv(23, th)
+
+	var data []byte
+	var offsets, counts []uint32
+	for ty := 0; ty < down; ty++ {
+		for tx := 0; tx < across; tx++ {
+			offsets = append(offsets, uint32(8+len(data)))
+			for y := ty * th; y < (ty+1)*th; y++ {
+				for x := tx * tw; x < (tx+1)*tw; x++ {
+					c := src.NRGBAAt(min(x, 36), min(y, 22)) // tuiles de bord complétées
+					data = append(data, c.R, c.G, c.B)
+				}
+			}
+			counts = append(counts, uint32(tw*th*3))
+		}
+	}
+
+	le := binary.LittleEndian
+	file := append([]byte("II*\x00"), make([]byte, 4)...)
+	file = append(file, data...)
+	arrays := map[uint16][]uint32{324: offsets, 325: counts}
+	arrayAt := map[uint16]uint32{}
+	for _, tag := range []uint16{324, 325} {
+		arrayAt[tag] = uint32(len(file))
+		for _, v := range arrays[tag] {
+			file = le.AppendUint32(file, v)
+		}
+	}
+	bitsAt := uint32(len(file))
+	file = le.AppendUint16(le.AppendUint16(le.AppendUint16(file, 8), 8), 8)
+
+	entries := []struct {
+		tag, typ uint16
+		count    uint32
+		value    uint32
+	}{
+		{256, 3, 1, 37}, {257, 3, 1, 23}, {258, 3, 3, bitsAt}, {259, 3, 1, 1}, {262, 3, 1, 2}, {277, 3, 1, 3},
+		{322, 3, 1, tw}, {323, 3, 1, th},
+		{324, 4, uint32(len(offsets)), arrayAt[324]}, {325, 4, uint32(len(counts)), arrayAt[325]},
+	}
+	le.PutUint32(file[4:], uint32(len(file)))
+	file = le.AppendUint16(file, uint16(len(entries)))
+	for _, e := range entries {
+		file = le.AppendUint16(le.AppendUint16(file, e.tag), e.typ)
+		file = le.AppendUint32(file, e.count)
+		if e.typ == 3 && e.count == 1 {
+			file = le.AppendUint16(le.AppendUint16(file, uint16(e.value)), 0)
+		} else {
+			file = le.AppendUint32(file, e.value)
+		}
+	}
+	file = le.AppendUint32(file, 0)
+
+	path := filepath.Join(t.TempDir(), "tuiles.tif")
+	if err := os.WriteFile(path, file, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	assertSameRGBA(t, "tiff en tuiles", readAllRows(t, path), src)
+}
+
+// Exemple de la spécification TIFF 6.0 (section 9)
+func TestPackBitsReader(t *testing.T) {
+	in := []byte{0xFE, 0xAA, 0x02, 0x80, 0x00, 0x2A, 0xFD, 0xAA, 0x03, 0x80, 0x00, 0x2A, 0x22, 0xF7, 0xAA}
+	want := []byte{0xAA, 0xAA, 0xAA, 0x80, 0x00, 0x2A, 0xAA, 0xAA, 0xAA, 0xAA, 0x80, 0x00, 0x2A, 0x22,
+		0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA}
+	got, err := io.ReadAll(&packBitsReader{r: bytes.NewReader(in)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("décompression % X, attendu % X", got, want)
+	}
+}
+
+func mustOpen(t *testing.T, path string) *os.File {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// Avec un budget trop petit pour décoder l'image entière, un PNG est découpé par bandes
+// et le même contenu en JPEG est refusé.
+func TestGenerateTilesStreamsUnderBudget(t *testing.T) {
+	saved := GetParallelLimits()
+	defer SetParallelLimits(saved)
+
+	dir := t.TempDir()
+	src := testPhoto(300, 3000)
+	var pngData, jpgData bytes.Buffer
+	if err := png.Encode(&pngData, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(&jpgData, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	pngPath, jpgPath := filepath.Join(dir, "grand.png"), filepath.Join(dir, "grand.jpg")
+	if err := os.WriteFile(pngPath, pngData.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(jpgPath, jpgData.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	SetParallelLimits(ParallelLimits{Workers: 2, MemoryBudget: 1 << 20})
+	if estimateMemory(pngPath) <= 1<<20 {
+		t.Fatal("budget de test trop grand pour l'image entière")
+	}
+
+	res, err := GenerateTiles(pngPath, dir, TileOptions{Format: "png"})
+	if err != nil {
+		t.Fatalf("PNG refusé : %v", err)
+	}
+	wantTiles := 0
+	for step := 0; step < res.Levels; step++ {
+		wantTiles += ceilDiv(ceilDiv(300, 1<<step), 254) * ceilDiv(ceilDiv(3000, 1<<step), 254)
+	}
+	if res.Levels != 13 || res.Tiles != wantTiles {
+		t.Fatalf("%d niveaux et %d tuiles, attendu 13 et %d", res.Levels, res.Tiles, wantTiles)
+	}
+
+	// Pleine résolution : la tuile 1_2 couvre (253,507)-(300,763), recouvrement compris
+	tile := decodeTile(t, filepath.Join(dir, "grand_files", "12", "1_2.png"))
+	assertSameRGBA(t, "tuile 12/1_2", tile, src.SubImage(image.Rect(253, 507, 300, 763)))
+
+	// Niveau réduit de moitié : moyenne des blocs de 2×2
+	half := decodeTile(t, filepath.Join(dir, "grand_files", "11", "0_3.png"))
+	for _, p := range []image.Point{{0, 0}, {40, 100}, {149, 252}} {
+		x, y := 2*p.X, 2*(p.Y+3*254-1)
+		for c := 0; c < 3; c++ {
+			sum := 0
+			for _, q := range []image.Point{{x, y}, {x + 1, y}, {x, y + 1}, {x + 1, y + 1}} {
+				sum += int(src.Pix[src.PixOffset(q.X, q.Y)+c])
+			}
+			got := int(half.Pix[half.PixOffset(p.X, p.Y)+c])
+			if d := got - (sum+2)/4; d < -1 || d > 1 {
+				t.Errorf("niveau 11, pixel %v canal %d = %d, attendu %d", p, c, got, (sum+2)/4)
+			}
+		}
+	}
+	if last := decodeTile(t, filepath.Join(dir, "grand_files", "0", "0_0.png")); last.Rect.Dx() != 1 || last.Rect.Dy() != 1 {
+		t.Errorf("dernier niveau de %v, 1×1 attendu", last.Rect.Size())
+	}
+
+	if _, err := GenerateTiles(jpgPath, dir, TileOptions{}); err == nil {
+		t.Error("JPEG décodé entièrement malgré le budget")
+	}
+}
+
+func TestGenerateTilesIIIF(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "img.png")
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testPhoto(300, 3000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := GenerateTiles(path, dir, TileOptions{Layout: TileLayoutIIIF})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Levels != 4 {
+		t.Errorf("%d niveaux, 4 attendus", res.Levels)
+	}
+	for _, p := range []string{
+		"info.json",
+		"0,2560,300,440/300,/0/default.jpg",
+		"0,2048,300,952/150,/0/default.jpg",
+		"full/38,/0/default.jpg",
+	} {
+		if _, err := os.Stat(filepath.Join(dir, "img", filepath.FromSlash(p))); err != nil {
+			t.Errorf("%s absent : %v", p, err)
+		}
+	}
+}
+
+func decodeTile(t *testing.T, path string) *image.RGBA {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(out, out.Rect, img, img.Bounds().Min, draw.Src)
+	return out
+}
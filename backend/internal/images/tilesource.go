@@ -0,0 +1,360 @@
+package images
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"os"
+)
+
+// Lecture de l'image source ligne par ligne pour le découpage en tuiles : les PNG non
+// entrelacés et les TIFF sont lus au fil de l'eau, sans décoder l'image entière.
+
+// rowSource fournit les lignes de l'image de haut en bas, en RGBA prémultiplié 8 bits.
+type rowSource interface {
+	size() (w, h int)
+	readRow(dst []uint8) error // len(dst) = 4×largeur
+	Close() error
+}
+
+// errNotStreamable signale une variante du format qui ne se lit pas par lignes
+var errNotStreamable = errors.New("lecture par bandes impossible")
+
+// openRowSource ouvre path pour une lecture par lignes. Les formats qui ne se lisent pas au
+// fil de l'eau sont décodés entièrement, dans la limite du budget mémoire.
+func openRowSource(path string) (rowSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("échec lecture '%s' : %w", path, err)
+	}
+	var head [4]byte
+	_, _ = io.ReadFull(f, head[:])
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("échec lecture '%s' : %w", path, err)
+	}
+
+	var src rowSource
+	switch string(head[:]) {
+	case "\x89PNG":
+		src, err = newPNGRows(f)
+	case "II*\x00", "MM\x00*", "II+\x00", "MM\x00+":
+		src, err = newTIFFRows(f)
+	default:
+		err = errNotStreamable
+	}
+	if err == nil {
+		return src, nil
+	}
+	f.Close()
+	if !errors.Is(err, errNotStreamable) {
+		return nil, fmt.Errorf("erreur de décodage de '%s' : %w", path, err)
+	}
+
+	if limit := GetParallelLimits().MemoryBudget; limit > 0 {
+		if need := estimateMemory(path); need > limit {
+			return nil, fmt.Errorf("image trop grande pour le budget mémoire (%d Mo nécessaires, %d Mo autorisés) : "+
+				"convertir la source en PNG ou TIFF, lus par bandes", need>>20, limit>>20)
+		}
+	}
+	img, err := decodeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &imageRows{img: img}, nil
+}
+
+// imageRows sert les lignes d'une image déjà décodée.
+type imageRows struct {
+	img image.Image
+	y   int
+}
+
+func (r *imageRows) size() (int, int) {
+	b := r.img.Bounds()
+	return b.Dx(), b.Dy()
+}
+
+func (r *imageRows) readRow(dst []uint8) error {
+	b := r.img.Bounds()
+	row := &image.RGBA{Pix: dst, Stride: len(dst), Rect: image.Rect(0, 0, b.Dx(), 1)}
+	draw.Draw(row, row.Rect, r.img, image.Pt(b.Min.X, b.Min.Y+r.y), draw.Src)
+	r.y++
+	return nil
+}
+
+func (r *imageRows) Close() error { return nil }
+
+// premul applique l'alpha à une composante, avec l'arrondi de image/draw.
+func premul(c, a uint8) uint8 {
+	return uint8(uint32(c) * (uint32(a) * 0x101) / 0xff >> 8)
+}
+
+// pngRows décode un PNG non entrelacé ligne par ligne.
+type pngRows struct {
+	f         *os.File
+	z         io.ReadCloser
+	w, h      int
+	depth     int
+	colorType uint8
+	palette   [][4]uint8 // RGBA prémultiplié
+	trns      []uint16   // couleur transparente (gris ou RVB), si présente
+	bpp       int        // octets par pixel pour les filtres (1 au minimum)
+	cur, prev []uint8
+}
+
+func newPNGRows(f *os.File) (*pngRows, error) {
+	br := bufio.NewReader(f)
+	if _, err := br.Discard(8); err != nil {
+		return nil, err
+	}
+	p := &pngRows{f: f}
+	var chunk [8]byte
+	for {
+		if _, err := io.ReadFull(br, chunk[:]); err != nil {
+			return nil, fmt.Errorf("PNG tronqué : %w", err)
+		}
+		n := binary.BigEndian.Uint32(chunk[:4])
+		kind := string(chunk[4:8])
+		if kind == "IDAT" {
+			break
+		}
+		if n > 1<<24 {
+			return nil, fmt.Errorf("bloc PNG '%s' trop grand", kind)
+		}
+		data := make([]byte, n+4) // CRC compris
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, fmt.Errorf("PNG tronqué : %w", err)
+		}
+		data = data[:n]
+		switch kind {
+		case "IHDR":
+			if err := p.header(data); err != nil {
+				return nil, err
+			}
+		case "PLTE":
+			p.palette = make([][4]uint8, len(data)/3)
+			for i := range p.palette {
+				p.palette[i] = [4]uint8{data[3*i], data[3*i+1], data[3*i+2], 255}
+			}
+		case "tRNS":
+			p.transparency(data)
+		case "eXIf":
+			if o := orientationFromTIFF(data); o > 1 {
+				return nil, errNotStreamable // rotation à appliquer sur l'image entière
+			}
+		}
+	}
+	if p.w == 0 {
+		return nil, errors.New("en-tête PNG absent")
+	}
+	if p.colorType == 3 && len(p.palette) == 0 {
+		return nil, errors.New("palette PNG absente")
+	}
+
+	z, err := zlib.NewReader(&idatReader{r: br, left: binary.BigEndian.Uint32(chunk[:4])})
+	if err != nil {
+		return nil, err
+	}
+	p.z = z
+	return p, nil
+}
+
+func (p *pngRows) header(d []byte) error {
+	if len(d) != 13 {
+		return errors.New("en-tête PNG invalide")
+	}
+	w, h := binary.BigEndian.Uint32(d[0:]), binary.BigEndian.Uint32(d[4:])
+	if w == 0 || h == 0 || w > 1<<30 || h > 1<<30 {
+		return errors.New("dimensions PNG invalides")
+	}
+	if d[12] != 0 {
+		return errNotStreamable // entrelacement Adam7
+	}
+	p.w, p.h, p.depth, p.colorType = int(w), int(h), int(d[8]), d[9]
+
+	valid := map[uint8][]int{0: {1, 2, 4, 8, 16}, 2: {8, 16}, 3: {1, 2, 4, 8}, 4: {8, 16}, 6: {8, 16}}
+	ok := false
+	for _, depth := range valid[p.colorType] {
+		ok = ok || depth == p.depth
+	}
+	if !ok {
+		return fmt.Errorf("PNG de type %d en %d bits invalide", p.colorType, p.depth)
+	}
+	channels := map[uint8]int{0: 1, 2: 3, 3: 1, 4: 2, 6: 4}[p.colorType]
+	bits := channels * p.depth
+	p.bpp = max(1, bits/8)
+	rowBytes := (p.w*bits + 7) / 8
+	p.cur = make([]uint8, 1+rowBytes)
+	p.prev = make([]uint8, 1+rowBytes)
+	return nil
+}
+
+func (p *pngRows) transparency(d []byte) {
+	switch p.colorType {
+	case 0:
+		if len(d) >= 2 {
+			p.trns = []uint16{binary.BigEndian.Uint16(d)}
+		}
+	case 2:
+		if len(d) >= 6 {
+			p.trns = []uint16{binary.BigEndian.Uint16(d), binary.BigEndian.Uint16(d[2:]), binary.BigEndian.Uint16(d[4:])}
+		}
+	case 3:
+		for i, a := range d {
+			if i < len(p.palette) {
+				c := &p.palette[i]
+				*c = [4]uint8{premul(c[0], a), premul(c[1], a), premul(c[2], a), a}
+			}
+		}
+	}
+}
+
+func (p *pngRows) size() (int, int) { return p.w, p.h }
+
+func (p *pngRows) Close() error {
+	p.z.Close()
+	return p.f.Close()
+}
+
+func (p *pngRows) readRow(dst []uint8) error {
+	p.cur, p.prev = p.prev, p.cur
+	if _, err := io.ReadFull(p.z, p.cur); err != nil {
+		return fmt.Errorf("données PNG tronquées : %w", err)
+	}
+	row, prev := p.cur[1:], p.prev[1:]
+	if err := unfilterPNG(p.cur[0], row, prev, p.bpp); err != nil {
+		return err
+	}
+
+	// sample lit la valeur brute du canal c du pixel x
+	sample := func(x, c, channels int) uint16 {
+		if p.depth == 16 {
+			return binary.BigEndian.Uint16(row[2*(x*channels+c):])
+		}
+		if p.depth == 8 {
+			return uint16(row[x*channels+c])
+		}
+		bit := x * p.depth
+		return uint16(row[bit/8]>>(8-p.depth-bit%8)) & (1<<p.depth - 1)
+	}
+	to8 := func(v uint16) uint8 {
+		switch p.depth {
+		case 16:
+			return uint8(v >> 8)
+		case 8:
+			return uint8(v)
+		}
+		return uint8(uint32(v) * 255 / (1<<p.depth - 1))
+	}
+
+	for x := 0; x < p.w; x++ {
+		px := dst[4*x : 4*x+4]
+		switch p.colorType {
+		case 0:
+			v := sample(x, 0, 1)
+			if len(p.trns) == 1 && v == p.trns[0] {
+				copy(px, []uint8{0, 0, 0, 0})
+				continue
+			}
+			g := to8(v)
+			copy(px, []uint8{g, g, g, 255})
+		case 2:
+			r, g, b := sample(x, 0, 3), sample(x, 1, 3), sample(x, 2, 3)
+			if len(p.trns) == 3 && r == p.trns[0] && g == p.trns[1] && b == p.trns[2] {
+				copy(px, []uint8{0, 0, 0, 0})
+				continue
+			}
+			copy(px, []uint8{to8(r), to8(g), to8(b), 255})
+		case 3:
+			i := int(sample(x, 0, 1))
+			if i >= len(p.palette) {
+				return errors.New("indice de palette PNG invalide")
+			}
+			copy(px, p.palette[i][:])
+		case 4:
+			g, a := to8(sample(x, 0, 2)), to8(sample(x, 1, 2))
+			g = premul(g, a)
+			copy(px, []uint8{g, g, g, a})
+		case 6:
+			a := to8(sample(x, 3, 4))
+			copy(px, []uint8{premul(to8(sample(x, 0, 4)), a), premul(to8(sample(x, 1, 4)), a), premul(to8(sample(x, 2, 4)), a), a})
+		}
+	}
+	return nil
+}
+
+// unfilterPNG annule le filtre d'une ligne PNG (None, Sub, Up, Average, Paeth).
+func unfilterPNG(filter uint8, cur, prev []uint8, bpp int) error {
+	switch filter {
+	case 0:
+	case 1:
+		for i := bpp; i < len(cur); i++ {
+			cur[i] += cur[i-bpp]
+		}
+	case 2:
+		for i := range cur {
+			cur[i] += prev[i]
+		}
+	case 3:
+		for i := range cur {
+			left := 0
+			if i >= bpp {
+				left = int(cur[i-bpp])
+			}
+			cur[i] += uint8((left + int(prev[i])) / 2)
+		}
+	case 4:
+		for i := range cur {
+			var a, c int
+			if i >= bpp {
+				a, c = int(cur[i-bpp]), int(prev[i-bpp])
+			}
+			b := int(prev[i])
+			pa, pb, pc := absInt(b-c), absInt(a-c), absInt(a+b-2*c)
+			switch {
+			case pa <= pb && pa <= pc:
+				cur[i] += uint8(a)
+			case pb <= pc:
+				cur[i] += uint8(b)
+			default:
+				cur[i] += uint8(c)
+			}
+		}
+	default:
+		return fmt.Errorf("filtre PNG %d inconnu", filter)
+	}
+	return nil
+}
+
+// idatReader enchaîne les données des blocs IDAT consécutifs.
+type idatReader struct {
+	r    *bufio.Reader
+	left uint32 // octets restant dans le bloc courant
+	done bool
+}
+
+func (d *idatReader) Read(b []byte) (int, error) {
+	for d.left == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		var next [12]byte // CRC du bloc courant, longueur et type du suivant
+		if _, err := io.ReadFull(d.r, next[:]); err != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if string(next[8:12]) != "IDAT" {
+			d.done = true
+			return 0, io.EOF
+		}
+		d.left = binary.BigEndian.Uint32(next[4:8])
+	}
+	n, err := d.r.Read(b[:min(uint32(len(b)), d.left)])
+	d.left -= uint32(n)
+	return n, err
+}
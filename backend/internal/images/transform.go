@@ -0,0 +1,48 @@
+package images
+
+import (
+	"image"
+	"image/color"
+)
+
+// applyTransforms applique les opérations géométriques demandées dans les options,
+// entre le décodage et l'encodage. orientation est l'orientation EXIF de la source.
+func applyTransforms(img image.Image, orientation int, opts *Options) (image.Image, error) {
+	// 1. Orientation EXIF, pour travailler sur l'image telle qu'elle est affichée
+	if !opts.IgnoreOrientation {
+		img = applyOrientation(img, orientation)
+	}
+
+	// 2. Rotation et miroirs
+	img, err := applyRotation(img, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Suppression du fond (couleur ou remplissage depuis les coins)
+	img, err = removeBackground(img, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. Suppression des bordures uniformes
+	if opts.Trim {
+		img = trimBorders(img, opts.TrimTolerance)
+	}
+
+	// 5. Recadrage
+	img, err = applyCrop(img, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// 6. Canevas de taille fixe
+	if opts.PadWidth > 0 && opts.PadHeight > 0 {
+		bg, err := parseHexColor(opts.PadColor, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		if err != nil {
+			return nil, err
+		}
+		img = padCanvas(img, opts.PadWidth, opts.PadHeight, bg)
+	}
+	return img, nil
+}
@@ -0,0 +1,118 @@
+package images
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+)
+
+const (
+	TransparencyNone  = ""      // aucune suppression de fond
+	TransparencyColor = "color" // la couleur choisie devient transparente partout dans l'image
+	TransparencyFlood = "flood" // fond uni supprimé par remplissage depuis les quatre coins
+)
+
+// supportsAlpha indique si le format de sortie conserve la transparence.
+func supportsAlpha(format string) bool {
+	switch strings.ToUpper(format) {
+	case "PNG", "WEBP", "TIFF":
+		return true
+	}
+	return false
+}
+
+// removeBackground rend transparents les pixels proches de la couleur de fond.
+// Les pixels dont l'écart dépasse la tolérance de moins de Feather reçoivent une
+// transparence partielle et sont débarrassés de la teinte du fond, pour des bords nets.
+func removeBackground(img image.Image, opts *Options) (image.Image, error) {
+	mode := strings.ToLower(opts.Transparency)
+	if mode == TransparencyNone {
+		return img, nil
+	}
+	if mode != TransparencyColor && mode != TransparencyFlood {
+		return nil, fmt.Errorf("mode de transparence '%s' inconnu", opts.Transparency)
+	}
+
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return dst, nil
+	}
+
+	tol := max(0, opts.TransparencyTolerance)
+	feather := max(0, opts.Feather)
+
+	if mode == TransparencyColor {
+		key, err := parseHexColor(opts.TransparentColor, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < len(dst.Pix); i += 4 {
+			keyPixel(dst.Pix[i:i+4], key, tol, feather)
+		}
+		return dst, nil
+	}
+
+	// Remplissage depuis chaque coin, comparé à la couleur de ce coin
+	visited := make([]bool, w*h)
+	stack := make([]int, 0, w+h)
+	for _, seed := range []int{0, w - 1, (h - 1) * w, h*w - 1} {
+		if visited[seed] {
+			continue
+		}
+		o := seed * 4
+		key := color.NRGBA{R: dst.Pix[o], G: dst.Pix[o+1], B: dst.Pix[o+2], A: 255}
+
+		stack = append(stack[:0], seed)
+		visited[seed] = true
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			keyPixel(dst.Pix[i*4:i*4+4], key, tol, feather)
+
+			x, y := i%w, i/w
+			for _, n := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				if n[0] < 0 || n[1] < 0 || n[0] >= w || n[1] >= h {
+					continue
+				}
+				j := n[1]*w + n[0]
+				if visited[j] || colorDistance(dst.Pix[j*4:j*4+4], key) > tol+feather {
+					continue
+				}
+				visited[j] = true
+				stack = append(stack, j)
+			}
+		}
+	}
+	return dst, nil
+}
+
+// colorDistance est l'écart maximal par canal entre un pixel NRGBA et la couleur de référence.
+func colorDistance(p []uint8, key color.NRGBA) int {
+	return max(absDiff(p[0], key.R), absDiff(p[1], key.G), absDiff(p[2], key.B))
+}
+
+// keyPixel applique la transparence à un pixel NRGBA selon son écart à la couleur de fond.
+func keyPixel(p []uint8, key color.NRGBA, tol, feather int) {
+	d := colorDistance(p, key)
+	if d <= tol {
+		p[3] = 0
+		return
+	}
+	if d >= tol+feather {
+		return
+	}
+
+	// Fondu : on retire la part de fond mélangée à la couleur (c = f·avant-plan + (1-f)·fond)
+	f := float64(d-tol) / float64(feather)
+	unmix := func(c, k uint8) uint8 {
+		v := (float64(c) - (1-f)*float64(k)) / f
+		return uint8(min(255, max(0, v+0.5)))
+	}
+	p[0], p[1], p[2] = unmix(p[0], key.R), unmix(p[1], key.G), unmix(p[2], key.B)
+	p[3] = uint8(float64(p[3])*f + 0.5)
+}
@@ -0,0 +1,86 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// trimBorders retire les bordures uniformes (couleur du pixel en haut à gauche,
+// à tolerance près sur chaque canal 8 bits).
+func trimBorders(img image.Image, tolerance int) image.Image {
+	b := img.Bounds()
+	ref := color.NRGBAModel.Convert(img.At(b.Min.X, b.Min.Y)).(color.NRGBA)
+
+	near := func(x, y int) bool {
+		c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+		return absDiff(c.R, ref.R) <= tolerance && absDiff(c.G, ref.G) <= tolerance &&
+			absDiff(c.B, ref.B) <= tolerance && absDiff(c.A, ref.A) <= tolerance
+	}
+	rowUniform := func(y, x0, x1 int) bool {
+		for x := x0; x < x1; x++ {
+			if !near(x, y) {
+				return false
+			}
+		}
+		return true
+	}
+	colUniform := func(x, y0, y1 int) bool {
+		for y := y0; y < y1; y++ {
+			if !near(x, y) {
+				return false
+			}
+		}
+		return true
+	}
+
+	top, bottom := b.Min.Y, b.Max.Y
+	for top < bottom && rowUniform(top, b.Min.X, b.Max.X) {
+		top++
+	}
+	if top == bottom {
+		// Image entièrement uniforme : rien à rogner
+		return img
+	}
+	for bottom > top && rowUniform(bottom-1, b.Min.X, b.Max.X) {
+		bottom--
+	}
+	left, right := b.Min.X, b.Max.X
+	for left < right && colUniform(left, top, bottom) {
+		left++
+	}
+	for right > left && colUniform(right-1, top, bottom) {
+		right--
+	}
+
+	return cropTo(img, image.Rect(left, top, right, bottom))
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// padCanvas centre l'image sur un canevas width×height rempli de bg.
+// Une image plus grande que le canevas est réduite pour y tenir.
+func padCanvas(img image.Image, width, height int, bg color.NRGBA) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > width || h > height {
+		scale := math.Min(float64(width)/float64(w), float64(height)/float64(h))
+		w = max(1, int(math.Round(float64(w)*scale)))
+		h = max(1, int(math.Round(float64(h)*scale)))
+		img = resize(img, w, h)
+		b = img.Bounds()
+	}
+
+	dst := newCanvasLike(img, image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	x := (width - w) / 2
+	y := (height - h) / 2
+	draw.Draw(dst, image.Rect(x, y, x+w, y+h), img, b.Min, draw.Over)
+	return dst
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"Altesse_Tools_V1.0/backend/internal/images"
+)
+
+// AtlasService assemble des images en atlas de textures (sprite sheets)
+type AtlasService struct{}
+
+func NewAtlasService() *AtlasService {
+	return &AtlasService{}
+}
+
+// Build assemble les images données dans outputDir
+func (a *AtlasService) Build(paths []string, outputDir string, opts images.AtlasOptions) (*images.AtlasResult, error) {
+	return images.BuildAtlas(paths, outputDir, opts)
+}
+
+// BuildFromFolder assemble toutes les images d'un dossier (non récursif)
+func (a *AtlasService) BuildFromFolder(folder string, outputDir string, opts images.AtlasOptions) (*images.AtlasResult, error) {
+	paths, err := listImages(folder)
+	if err != nil {
+		return nil, err
+	}
+	return images.BuildAtlas(paths, outputDir, opts)
+}
+
+var imageExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".webp": true,
+	".bmp": true, ".tif": true, ".tiff": true, ".gif": true,
+}
+
+// listImages retourne les images d'un dossier triées par nom
+func listImages(folder string) ([]string, error) {
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return nil, fmt.Errorf("impossible de lire le dossier '%s' : %w", folder, err)
+	}
+
+	var paths []string
+	for _, e := range entries {
+		if e.IsDir() || !imageExtensions[strings.ToLower(filepath.Ext(e.Name()))] {
+			continue
+		}
+		paths = append(paths, filepath.Join(folder, e.Name()))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"Altesse_Tools_V1.0/backend/internal/images"
+)
+
+// BarcodeService génère et lit des QR codes et des codes-barres (Code 128, EAN-13)
+type BarcodeService struct{}
+
+func NewBarcodeService() *BarcodeService {
+	return &BarcodeService{}
+}
+
+// Generate produit le code demandé en PNG, SVG, JPEG ou WebP dans output
+func (b *BarcodeService) Generate(opts images.CodeOptions, output string) (*images.CodeResult, error) {
+	return images.GenerateCode(opts, output)
+}
+
+// Read cherche les codes présents dans chaque image (contenu et position)
+func (b *BarcodeService) Read(paths []string) ([]images.CodeScan, error) {
+	return images.ReadCodesFromFiles(paths)
+}
+
+// ReadFolder lit les codes de toutes les images d'un dossier (non récursif)
+func (b *BarcodeService) ReadFolder(folder string) ([]images.CodeScan, error) {
+	paths, err := listImages(folder)
+	if err != nil {
+		return nil, err
+	}
+	return images.ReadCodesFromFiles(paths)
+}
@@ -0,0 +1,17 @@
+package services
+
+import (
+	"Altesse_Tools_V1.0/backend/internal/images"
+)
+
+// CompareService compare visuellement deux images
+type CompareService struct{}
+
+func NewCompareService() *CompareService {
+	return &CompareService{}
+}
+
+// Compare mesure les différences entre deux images et écrit l'image des différences si demandé
+func (c *CompareService) Compare(pathA string, pathB string, opts images.DiffOptions) (*images.DiffResult, error) {
+	return images.CompareFiles(pathA, pathB, opts)
+}
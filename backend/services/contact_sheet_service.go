@@ -0,0 +1,26 @@
+package services
+
+import (
+	"Altesse_Tools_V1.0/backend/internal/images"
+)
+
+// ContactSheetService produit des planches contact (grilles de vignettes)
+type ContactSheetService struct{}
+
+func NewContactSheetService() *ContactSheetService {
+	return &ContactSheetService{}
+}
+
+// Build dispose les images données en grille et écrit la planche dans output
+func (c *ContactSheetService) Build(paths []string, output string, opts images.ContactSheetOptions) (*images.ContactSheetResult, error) {
+	return images.BuildContactSheet(paths, output, opts)
+}
+
+// BuildFromFolder crée la planche contact de toutes les images d'un dossier (non récursif)
+func (c *ContactSheetService) BuildFromFolder(folder string, output string, opts images.ContactSheetOptions) (*images.ContactSheetResult, error) {
+	paths, err := listImages(folder)
+	if err != nil {
+		return nil, err
+	}
+	return images.BuildContactSheet(paths, output, opts)
+}
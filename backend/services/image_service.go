@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"mime"
 	"os"
@@ -39,7 +40,7 @@ func (c *ConverterService) ConvertManyFromFilesToBase64Parallel(paths []string,
 	var mu sync.Mutex
 	converted := 0
 
-	_, err := images.ParallelConvert(paths, func(i int, path string) ([]byte, error) {
+	_, err := images.ParallelConvertWithCost(paths, images.ConversionCost(opts), func(i int, path string) ([]byte, error) {
 		// Taille avant conversion
 		info, _ := os.Stat(path)
 		originalSize := info.Size()
@@ -96,7 +97,7 @@ func (c *ConverterService) ConvertManyToFolder(paths []string, outputDir string,
 	var mu sync.Mutex
 	converted := 0
 
-	_, err := images.ParallelConvert(paths, func(i int, path string) ([]byte, error) {
+	_, err := images.ParallelConvertWithCost(paths, images.ConversionCost(opts), func(i int, path string) ([]byte, error) {
 		// Taille avant conversion
 		info, _ := os.Stat(path)
 		originalSize := info.Size()
@@ -148,6 +149,71 @@ func (c *ConverterService) ConvertManyToFolder(paths []string, outputDir string,
 	return outputPaths, nil
 }
 
+// GenerateResponsiveSet produit une famille srcset (plusieurs largeurs et formats) dans outputDir
+func (c *ConverterService) GenerateResponsiveSet(path string, outputDir string, opts images.ResponsiveOptions) (*images.ResponsiveSet, error) {
+	return images.GenerateResponsiveSet(path, outputDir, opts)
+}
+
+// GeneratePlaceholders calcule BlurHash, ThumbHash et LQIP de chaque image.
+// Si outputJSON est renseigné, le résultat y est aussi écrit.
+func (c *ConverterService) GeneratePlaceholders(paths []string, outputJSON string) (map[string]*images.Placeholders, error) {
+	results := make([]*images.Placeholders, len(paths))
+
+	_, err := images.ParallelConvert(paths, func(i int, path string) ([]byte, error) {
+		p, err := images.PlaceholdersFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = p
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	placeholders := make(map[string]*images.Placeholders, len(paths))
+	for i, path := range paths {
+		placeholders[path] = results[i]
+	}
+
+	if outputJSON != "" {
+		data, err := json.MarshalIndent(placeholders, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(outputJSON, data, 0o644); err != nil {
+			return nil, fmt.Errorf("échec écriture '%s' : %w", outputJSON, err)
+		}
+	}
+
+	return placeholders, nil
+}
+
+// ExportDataURIs convertit les images et les exporte en data URI (feuille CSS et/ou table JSON)
+func (c *ConverterService) ExportDataURIs(paths []string, opts images.DataURIOptions) (*images.DataURIExport, error) {
+	return images.ExportDataURIs(paths, opts)
+}
+
+// Estimate convertit en mémoire un échantillon de sampleSize fichiers (30 par défaut) et
+// extrapole la taille de sortie, le gain et la durée du lot complet
+func (c *ConverterService) Estimate(paths []string, opts *images.Options, sampleSize int) (*images.ConversionEstimate, error) {
+	return images.EstimateConversion(paths, opts, sampleSize)
+}
+
+// SetParallelLimits règle le nombre de workers et le budget mémoire (en Mo, 0 = illimité)
+// des conversions parallèles
+func (c *ConverterService) SetParallelLimits(workers int, memoryMB int) {
+	images.SetParallelLimits(images.ParallelLimits{
+		Workers:      workers,
+		MemoryBudget: int64(memoryMB) << 20,
+	})
+}
+
+// GetParallelLimits retourne les limites actuelles des conversions parallèles
+func (c *ConverterService) GetParallelLimits() images.ParallelLimits {
+	return images.GetParallelLimits()
+}
+
 func (c *ConverterService) GetImagePreview(filePath string) (string, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
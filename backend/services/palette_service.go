@@ -0,0 +1,39 @@
+package services
+
+import (
+	"Altesse_Tools_V1.0/backend/internal/images"
+)
+
+// PaletteService extrait les couleurs dominantes des images
+type PaletteService struct{}
+
+func NewPaletteService() *PaletteService {
+	return &PaletteService{}
+}
+
+// Extract retourne les couleurs dominantes d'une image
+func (p *PaletteService) Extract(path string, opts images.PaletteOptions) (*images.Palette, error) {
+	return images.ExtractPaletteFromFile(path, opts)
+}
+
+// ExtractFolder retourne les palettes de toutes les images d'un dossier (non récursif)
+func (p *PaletteService) ExtractFolder(folder string, opts images.PaletteOptions) ([]*images.Palette, error) {
+	paths, err := listImages(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	palettes := make([]*images.Palette, len(paths))
+	_, err = images.ParallelConvert(paths, func(i int, path string) ([]byte, error) {
+		palette, err := images.ExtractPaletteFromFile(path, opts)
+		if err != nil {
+			return nil, err
+		}
+		palettes[i] = palette
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return palettes, nil
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"Altesse_Tools_V1.0/backend/internal/images"
+)
+
+// PDFService assemble des images en documents PDF
+type PDFService struct{}
+
+func NewPDFService() *PDFService {
+	return &PDFService{}
+}
+
+// FromImages écrit les images, dans l'ordre donné, dans un PDF multipage
+func (p *PDFService) FromImages(paths []string, output string, opts images.PDFOptions) (*images.PDFResult, error) {
+	return images.ImagesToPDF(paths, output, opts)
+}
+
+// FromFolder assemble toutes les images d'un dossier, triées par nom, dans un PDF
+func (p *PDFService) FromFolder(folder string, output string, opts images.PDFOptions) (*images.PDFResult, error) {
+	paths, err := listImages(folder)
+	if err != nil {
+		return nil, err
+	}
+	return images.ImagesToPDF(paths, output, opts)
+}
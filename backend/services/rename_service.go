@@ -13,3 +13,38 @@ func NewRenameService() *RenameService {
 func (r *RenameService) Rename(paths []string, opts files.OptionRename) error {
 	return files.RenameBatch(paths, opts)
 }
+
+// Preview simule le renommage : paires ancien → nouveau et conflits, sans rien modifier
+func (r *RenameService) Preview(paths []string, opts files.OptionRename) *files.RenamePreview {
+	return files.PreviewRename(paths, opts)
+}
+
+// History liste les lots de renommage du journal, du plus récent au plus ancien
+func (r *RenameService) History() ([]files.RenameJournalEntry, error) {
+	return files.RenameHistory()
+}
+
+// Undo annule un lot de renommage si ses fichiers n'ont pas été déplacés depuis
+func (r *RenameService) Undo(batchID string) error {
+	return files.UndoRenameBatch(batchID)
+}
+
+// RenameWithRecipe renomme les fichiers en appliquant les règles de la recette dans l'ordre
+func (r *RenameService) RenameWithRecipe(paths []string, recipe files.RenameRecipe) error {
+	return files.RenameWithRecipe(paths, recipe)
+}
+
+// PreviewRecipe simule une recette : paires ancien → nouveau et conflits, sans rien modifier
+func (r *RenameService) PreviewRecipe(paths []string, recipe files.RenameRecipe) (*files.RenamePreview, error) {
+	return files.PreviewRecipe(paths, recipe)
+}
+
+// SaveRecipe enregistre une recette de renommage en JSON
+func (r *RenameService) SaveRecipe(path string, recipe files.RenameRecipe) error {
+	return files.SaveRecipe(path, recipe)
+}
+
+// LoadRecipe charge une recette de renommage enregistrée
+func (r *RenameService) LoadRecipe(path string) (*files.RenameRecipe, error) {
+	return files.LoadRecipe(path)
+}
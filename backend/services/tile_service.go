@@ -0,0 +1,22 @@
+package services
+
+import (
+	"Altesse_Tools_V1.0/backend/internal/images"
+)
+
+// TileService découpe les images : pyramides de tuiles (DZI, IIIF) et grilles de morceaux
+type TileService struct{}
+
+func NewTileService() *TileService {
+	return &TileService{}
+}
+
+// Generate découpe l'image en pyramide de tuiles dans outputDir
+func (t *TileService) Generate(path string, outputDir string, opts images.TileOptions) (*images.TileResult, error) {
+	return images.GenerateTiles(path, outputDir, opts)
+}
+
+// Split découpe l'image en grille (N×M ou taille fixe) dans outputDir
+func (t *TileService) Split(path string, outputDir string, opts images.SplitOptions) (*images.SplitResult, error) {
+	return images.SplitImage(path, outputDir, opts)
+}
@@ -18,6 +18,7 @@ require (
 require (
 	github.com/Kagami/go-avif v0.1.0
 	github.com/bep/debounce v1.2.1 // indirect
+	github.com/boombuler/barcode v1.1.0
 	github.com/chai2010/webp v1.4.0
 	github.com/go-ole/go-ole v1.3.0 // indirect
 	github.com/godbus/dbus/v5 v5.1.0 // indirect
@@ -45,7 +46,7 @@ require (
 	golang.org/x/image v0.31.0
 	golang.org/x/net v0.35.0 // indirect
 	golang.org/x/sys v0.35.0 // indirect
-	golang.org/x/text v0.29.0 // indirect
+	golang.org/x/text v0.29.0
 )
 
 // replace github.com/wailsapp/wails/v2 v2.10.1 => C:\Users\shiro\go\pkg\mod
@@ -23,6 +23,13 @@ func main() {
 	stats := service.NewStatsService()
 	rename := service.NewRenameService()
 	duplicate := service.NewDuplicateService()
+	atlas := service.NewAtlasService()
+	contactSheet := service.NewContactSheetService()
+	pdf := service.NewPDFService()
+	tiles := service.NewTileService()
+	palette := service.NewPaletteService()
+	compare := service.NewCompareService()
+	barcodes := service.NewBarcodeService()
 	err := wails.Run(&options.App{
 		Title:     "Altesse_Tools_V1.0",
 		Width:     1250,
@@ -49,6 +56,13 @@ func main() {
 			stats,
 			rename,
 			duplicate,
+			atlas,
+			contactSheet,
+			pdf,
+			tiles,
+			palette,
+			compare,
+			barcodes,
 		},
 	})
 